@@ -2,26 +2,159 @@ package main
 
 import (
 	"bufio"
+	"encoding/csv"
 	"flag"
 	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+	"math"
+	"math/bits"
 	"math/rand"
 	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
+type variant int
+
+const (
+	variantQueen variant = iota
+	variantToroidal
+	variantSuperQueen
+	variantKing
+	variantRook
+)
+
+var variantNames = map[string]variant{
+	"queen":      variantQueen,
+	"toroidal":   variantToroidal,
+	"superqueen": variantSuperQueen,
+	"amazon":     variantSuperQueen,
+	"king":       variantKing,
+	"rook":       variantRook,
+}
+
+func (v variant) attacksRows() bool {
+	return v != variantKing
+}
+
+func (v variant) attacksDiagonals() bool {
+	return v == variantQueen || v == variantToroidal || v == variantSuperQueen
+}
+
+func (v variant) solvable(n int) bool {
+	if n < 1 {
+		return false
+	}
+	if n == 1 {
+		return true
+	}
+	switch v {
+	case variantToroidal:
+		return gcd(n, 6) == 1
+	case variantSuperQueen:
+		return n >= 10
+	case variantKing:
+		return n != 2
+	case variantRook:
+		return true
+	}
+	return n >= 4
+}
+
+func (v variant) budget(n int) (restarts, steps int) {
+	switch v {
+	case variantToroidal, variantSuperQueen:
+		return 500, 20 * n
+	}
+	return 8, 5 * n
+}
+
+func gcd(a, b int) int {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
+
+type strategy int
+
+const (
+	strategyMinConflicts strategy = iota
+	strategyTabu
+	strategyAnnealing
+	strategyWalk
+)
+
+var strategyNames = map[string]strategy{
+	"minconflicts": strategyMinConflicts,
+	"tabu":         strategyTabu,
+	"annealing":    strategyAnnealing,
+	"walk":         strategyWalk,
+}
+
+var strategyOrder = []string{"minconflicts", "tabu", "annealing", "walk"}
+
+const minTemperature = 0.05
+
+type searchConfig struct {
+	strategy    strategy
+	tabuTenure  int
+	noise       float64
+	temperature float64
+	cooling     float64
+}
+
+func (cfg searchConfig) stepFactor() int {
+	if cfg.strategy == strategyAnnealing {
+		return 20
+	}
+	return 1
+}
+
+type cell struct {
+	row int
+	col int
+}
+
 type solver struct {
 	n           int
+	variant     variant
+	rng         *rand.Rand
+	stop        *atomic.Bool
+	steps       int
+	restarts    int
+	search      searchConfig
+	tabuUntil   []int
+	temperature float64
+	trace       *tracer
 	queens      []int
 	rowCnt      []int
 	diagMainCnt []int
 	diagAntiCnt []int
+
+	// Obstacle boards only: blocked cells and the segment of each line
+	// family a cell belongs to, indexed by row*n+col.
+	blocked []bool
+	rowSeg  []int32
+	mainSeg []int32
+	antiSeg []int32
 }
 
-func newSolver(n int) *solver {
+func newSolver(n int, v variant, obstacles []cell, rng *rand.Rand) *solver {
 	s := &solver{
 		n:           n,
+		variant:     v,
+		rng:         rng,
 		queens:      make([]int, n),
 		rowCnt:      make([]int, n),
 		diagMainCnt: make([]int, 2*n-1),
@@ -30,17 +163,92 @@ func newSolver(n int) *solver {
 	for i := range s.queens {
 		s.queens[i] = -1
 	}
+	if len(obstacles) > 0 {
+		s.blocked = make([]bool, n*n)
+		for _, o := range obstacles {
+			s.blocked[o.row*n+o.col] = true
+		}
+		s.buildSegments()
+	}
 	s.initBoard()
+	s.evictBlocked()
 	return s
 }
 
+func (s *solver) buildSegments() {
+	n := s.n
+	s.rowSeg = make([]int32, n*n)
+	s.mainSeg = make([]int32, n*n)
+	s.antiSeg = make([]int32, n*n)
+
+	rows, mains, antis := int32(0), int32(0), int32(0)
+	for r := 0; r < n; r++ {
+		s.segmentLine(s.rowSeg, &rows, r, 0, 0, 1)
+	}
+	for c := 0; c < n; c++ {
+		s.segmentLine(s.mainSeg, &mains, 0, c, 1, 1)
+		s.segmentLine(s.antiSeg, &antis, 0, c, 1, -1)
+	}
+	for r := 1; r < n; r++ {
+		s.segmentLine(s.mainSeg, &mains, r, 0, 1, 1)
+		s.segmentLine(s.antiSeg, &antis, r, n-1, 1, -1)
+	}
+	s.rowCnt = make([]int, rows)
+	s.diagMainCnt = make([]int, mains)
+	s.diagAntiCnt = make([]int, antis)
+}
+
+func (s *solver) segmentLine(seg []int32, next *int32, r, c, dr, dc int) {
+	open := false
+	for ; r >= 0 && r < s.n && c >= 0 && c < s.n; r, c = r+dr, c+dc {
+		if s.blocked[r*s.n+c] {
+			open = false
+			continue
+		}
+		if !open {
+			*next++
+			open = true
+		}
+		seg[r*s.n+c] = *next - 1
+	}
+}
+
+func (s *solver) isBlocked(row, col int) bool {
+	return s.blocked != nil && s.blocked[row*s.n+col]
+}
+
+func (s *solver) hasFullyBlockedColumn() bool {
+	if s.blocked == nil {
+		return false
+	}
+	for col := 0; col < s.n; col++ {
+		free := false
+		for row := 0; row < s.n && !free; row++ {
+			free = !s.blocked[row*s.n+col]
+		}
+		if !free {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *solver) evictBlocked() {
+	if s.blocked == nil || s.hasFullyBlockedColumn() {
+		return
+	}
+	for col, row := range s.queens {
+		if s.isBlocked(row, col) {
+			s.place(col, s.rowWithMinConflicts(col))
+		}
+	}
+}
+
 func (s *solver) initBoard() {
 	col := 1
 	for row := 0; row < s.n; row++ {
 		s.queens[col] = row
-		s.rowCnt[row]++
-		s.diagMainCnt[row-col+s.n-1]++
-		s.diagAntiCnt[row+col]++
+		s.mark(row, col, 1)
 		col += 2
 		if col >= s.n {
 			col = 0
@@ -48,6 +256,39 @@ func (s *solver) initBoard() {
 	}
 }
 
+func (s *solver) rowLine(row, col int) int {
+	if s.blocked != nil {
+		return int(s.rowSeg[row*s.n+col])
+	}
+	return row
+}
+
+func (s *solver) mainDiag(row, col int) int {
+	if s.blocked != nil {
+		return int(s.mainSeg[row*s.n+col])
+	}
+	if s.variant == variantToroidal {
+		return ((row-col)%s.n + s.n) % s.n
+	}
+	return row - col + s.n - 1
+}
+
+func (s *solver) antiDiag(row, col int) int {
+	if s.blocked != nil {
+		return int(s.antiSeg[row*s.n+col])
+	}
+	if s.variant == variantToroidal {
+		return (row + col) % s.n
+	}
+	return row + col
+}
+
+func (s *solver) mark(row, col, delta int) {
+	s.rowCnt[s.rowLine(row, col)] += delta
+	s.diagMainCnt[s.mainDiag(row, col)] += delta
+	s.diagAntiCnt[s.antiDiag(row, col)] += delta
+}
+
 func (s *solver) place(col, row int) {
 	if s.queens[col] != -1 {
 		if s.queens[col] == row {
@@ -56,29 +297,85 @@ func (s *solver) place(col, row int) {
 		s.remove(col, s.queens[col])
 	}
 	s.queens[col] = row
-	s.rowCnt[row]++
-	s.diagMainCnt[row-col+s.n-1]++
-	s.diagAntiCnt[row+col]++
+	s.mark(row, col, 1)
 }
 
 func (s *solver) remove(col, row int) {
-	s.rowCnt[row]--
-	s.diagMainCnt[row-col+s.n-1]--
-	s.diagAntiCnt[row+col]--
+	s.mark(row, col, -1)
 }
 
 func (s *solver) conflictsAt(row, col int) int {
-	c := s.rowCnt[row] + s.diagMainCnt[row-col+s.n-1] + s.diagAntiCnt[row+col]
+	self := 0
 	if s.queens[col] == row {
-		c -= 3
+		self = 1
+	}
+	c := 0
+	if s.variant.attacksRows() {
+		c += s.rowCnt[s.rowLine(row, col)] - self
+	}
+	if s.variant.attacksDiagonals() {
+		c += s.diagMainCnt[s.mainDiag(row, col)] + s.diagAntiCnt[s.antiDiag(row, col)] - 2*self
+	}
+	switch s.variant {
+	case variantSuperQueen:
+		c += s.knightConflicts(row, col)
+	case variantKing:
+		c += s.kingConflicts(row, col)
+	}
+	return c
+}
+
+func (s *solver) knightConflicts(row, col int) int {
+	c := 0
+	for _, dc := range [...]int{-2, -1, 1, 2} {
+		other := col + dc
+		if other < 0 || other >= s.n || s.queens[other] < 0 {
+			continue
+		}
+		if abs(s.queens[other]-row)+abs(dc) == 3 {
+			c++
+		}
 	}
 	return c
 }
 
+func (s *solver) kingConflicts(row, col int) int {
+	c := 0
+	for _, dc := range [...]int{-1, 1} {
+		other := col + dc
+		if other < 0 || other >= s.n || s.queens[other] < 0 {
+			continue
+		}
+		dr := s.queens[other] - row
+		if dr >= -1 && dr <= 1 {
+			c++
+		}
+	}
+	return c
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
 func (s *solver) colWithMaxConflicts() int {
+	col, c := s.maxConflictCol(true)
+	if c <= 0 && s.tabuUntil != nil {
+		col, _ = s.maxConflictCol(false)
+	}
+	return col
+}
+
+func (s *solver) maxConflictCol(skipTabu bool) (int, int) {
 	maxC := -1
 	cands := make([]int, 0, s.n)
 	for col := 0; col < s.n; col++ {
+		if skipTabu && s.tabuUntil != nil && s.tabuUntil[col] > s.steps {
+			continue
+		}
 		row := s.queens[col]
 		c := s.conflictsAt(row, col)
 		if c > maxC {
@@ -89,13 +386,65 @@ func (s *solver) colWithMaxConflicts() int {
 			cands = append(cands, col)
 		}
 	}
-	return cands[rand.Intn(len(cands))]
+	if len(cands) == 0 {
+		return -1, -1
+	}
+	return cands[s.rng.Intn(len(cands))], maxC
+}
+
+func (s *solver) randomConflictedCol() int {
+	cands := make([]int, 0, s.n)
+	for col := 0; col < s.n; col++ {
+		if s.conflictsAt(s.queens[col], col) > 0 {
+			cands = append(cands, col)
+		}
+	}
+	return cands[s.rng.Intn(len(cands))]
+}
+
+func (s *solver) randomFreeRow(col int) int {
+	for {
+		row := s.rng.Intn(s.n)
+		if !s.isBlocked(row, col) {
+			return row
+		}
+	}
+}
+
+func (s *solver) step() {
+	switch s.search.strategy {
+	case strategyTabu:
+		col := s.colWithMaxConflicts()
+		s.place(col, s.rowWithMinConflicts(col))
+		s.tabuUntil[col] = s.steps + s.search.tabuTenure
+	case strategyAnnealing:
+		col := s.randomConflictedCol()
+		row := s.randomFreeRow(col)
+		delta := s.conflictsAt(row, col) - s.conflictsAt(s.queens[col], col)
+		if delta <= 0 || s.rng.Float64() < math.Exp(-float64(delta)/s.temperature) {
+			s.place(col, row)
+		}
+		s.temperature = math.Max(s.temperature*s.search.cooling, minTemperature)
+	case strategyWalk:
+		col := s.colWithMaxConflicts()
+		if s.rng.Float64() < s.search.noise {
+			s.place(col, s.randomFreeRow(col))
+		} else {
+			s.place(col, s.rowWithMinConflicts(col))
+		}
+	default:
+		col := s.colWithMaxConflicts()
+		s.place(col, s.rowWithMinConflicts(col))
+	}
 }
 
 func (s *solver) rowWithMinConflicts(col int) int {
 	minC := int(1<<31 - 1)
 	cands := make([]int, 0, s.n)
 	for row := 0; row < s.n; row++ {
+		if s.isBlocked(row, col) {
+			continue
+		}
 		c := s.conflictsAt(row, col)
 		if c < minC {
 			minC = c
@@ -105,7 +454,7 @@ func (s *solver) rowWithMinConflicts(col int) int {
 			cands = append(cands, row)
 		}
 	}
-	return cands[rand.Intn(len(cands))]
+	return cands[s.rng.Intn(len(cands))]
 }
 
 func (s *solver) hasConflicts() bool {
@@ -118,23 +467,40 @@ func (s *solver) hasConflicts() bool {
 }
 
 func (s *solver) solve() []int {
-	if s.n <= 3 {
+	if s.blocked == nil && !s.variant.solvable(s.n) {
 		return nil
 	}
-	maxRestarts := 8
-	maxSteps := 5 * s.n
+	if s.hasFullyBlockedColumn() {
+		return nil
+	}
+	maxRestarts, maxSteps := s.variant.budget(s.n)
+	if s.blocked != nil {
+		maxRestarts, maxSteps = 500, 20*s.n
+	}
+	maxSteps *= s.search.stepFactor()
+	if s.search.strategy == strategyTabu {
+		s.tabuUntil = make([]int, s.n)
+	}
 
 	for r := 0; r < maxRestarts; r++ {
 		if r > 0 {
+			s.restarts++
 			s.resetRandom()
+			s.evictBlocked()
 		}
+		s.temperature = s.search.temperature
 		for step := 0; step < maxSteps; step++ {
+			if s.trace != nil {
+				s.trace.record(s)
+			}
 			if !s.hasConflicts() {
 				return s.queens
 			}
-			col := s.colWithMaxConflicts()
-			row := s.rowWithMinConflicts(col)
-			s.place(col, row)
+			if s.stop != nil && s.stop.Load() {
+				return nil
+			}
+			s.steps++
+			s.step()
 		}
 	}
 	return nil
@@ -157,23 +523,541 @@ func (s *solver) resetRandom() {
 	for i := range cols {
 		cols[i] = i
 	}
-	rand.Shuffle(s.n, func(i, j int) { cols[i], cols[j] = cols[j], cols[i] })
+	s.rng.Shuffle(s.n, func(i, j int) { cols[i], cols[j] = cols[j], cols[i] })
 	rows := make([]int, s.n)
 	for i := 0; i < s.n; i++ {
 		rows[i] = i
 	}
-	rand.Shuffle(s.n, func(i, j int) { rows[i], rows[j] = rows[j], rows[i] })
+	s.rng.Shuffle(s.n, func(i, j int) { rows[i], rows[j] = rows[j], rows[i] })
 	for i, col := range cols {
 		row := rows[i]
 		s.queens[col] = row
-		s.rowCnt[row]++
-		s.diagMainCnt[row-col+s.n-1]++
-		s.diagAntiCnt[row+col]++
+		s.mark(row, col, 1)
 	}
 }
 
-func printBoard(sol []int) {
+func construct(n int) []int {
+	if n != 1 && n < 4 {
+		return nil
+	}
+	evens := make([]int, 0, n/2)
+	for r := 2; r <= n; r += 2 {
+		evens = append(evens, r)
+	}
+	odds := make([]int, 0, (n+1)/2)
+	for r := 1; r <= n; r += 2 {
+		odds = append(odds, r)
+	}
+	switch n % 6 {
+	case 2:
+		odds[0], odds[1] = odds[1], odds[0]
+		odds = append(append(odds[:2:2], odds[3:]...), 5)
+	case 3:
+		evens = append(evens[1:], 2)
+		odds = append(odds[2:], 1, 3)
+	}
+	sol := make([]int, 0, n)
+	for _, r := range append(evens, odds...) {
+		sol = append(sol, r-1)
+	}
+	return sol
+}
+
+func verifySolution(sol []int, v variant) bool {
 	n := len(sol)
+	if n == 0 {
+		return false
+	}
+	s := &solver{
+		n:           n,
+		variant:     v,
+		queens:      make([]int, n),
+		rowCnt:      make([]int, n),
+		diagMainCnt: make([]int, 2*n-1),
+		diagAntiCnt: make([]int, 2*n-1),
+	}
+	for col, row := range sol {
+		if row < 0 || row >= n {
+			return false
+		}
+		s.queens[col] = row
+		s.mark(row, col, 1)
+	}
+	return !s.hasConflicts()
+}
+
+type portfolioResult struct {
+	sol      []int
+	worker   int
+	workers  int // number of workers actually started
+	steps    int
+	restarts int
+}
+
+func solvePortfolio(n int, v variant, obstacles []cell, cfg searchConfig, workers int, seed int64) portfolioResult {
+	if workers < 1 {
+		workers = 1
+	}
+	master := rand.New(rand.NewSource(seed))
+	seeds := make([]int64, workers)
+	for w := range seeds {
+		seeds[w] = master.Int63()
+	}
+
+	var stop atomic.Bool
+	won := make(chan portfolioResult, 1)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			s := newSolver(n, v, obstacles, rand.New(rand.NewSource(seeds[w])))
+			s.search = cfg
+			s.stop = &stop
+			if w > 0 {
+				s.resetRandom()
+				s.evictBlocked()
+			}
+			sol := s.solve()
+			if sol != nil && stop.CompareAndSwap(false, true) {
+				won <- portfolioResult{sol: sol, worker: w, workers: workers, steps: s.steps, restarts: s.restarts}
+			}
+		}(w)
+	}
+	wg.Wait()
+	close(won)
+
+	if res, ok := <-won; ok {
+		return res
+	}
+	return portfolioResult{worker: -1, workers: workers}
+}
+
+type traceRow struct {
+	step      int
+	restart   int
+	conflicts int
+}
+
+type heatmap struct {
+	step   int
+	queens []int
+	cells  []int
+}
+
+type tracer struct {
+	rows      []traceRow
+	snapshots map[int]bool
+	heatmaps  []heatmap
+}
+
+func (t *tracer) record(s *solver) {
+	total := 0
+	for col, row := range s.queens {
+		total += s.conflictsAt(row, col)
+	}
+	t.rows = append(t.rows, traceRow{step: s.steps, restart: s.restarts, conflicts: total / 2})
+
+	if !t.snapshots[s.steps] {
+		return
+	}
+	delete(t.snapshots, s.steps)
+	h := heatmap{
+		step:   s.steps,
+		queens: append([]int(nil), s.queens...),
+		cells:  make([]int, s.n*s.n),
+	}
+	for row := 0; row < s.n; row++ {
+		for col := 0; col < s.n; col++ {
+			if !s.isBlocked(row, col) {
+				h.cells[row*s.n+col] = s.conflictsAt(row, col)
+			}
+		}
+	}
+	t.heatmaps = append(t.heatmaps, h)
+}
+
+func (t *tracer) writeCSV(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	w := csv.NewWriter(f)
+	w.Write([]string{"step", "restart", "conflicts"})
+	for _, r := range t.rows {
+		w.Write([]string{strconv.Itoa(r.step), strconv.Itoa(r.restart), strconv.Itoa(r.conflicts)})
+	}
+	w.Flush()
+	return w.Error()
+}
+
+func parseSteps(list string) (map[int]bool, error) {
+	steps := make(map[int]bool)
+	for _, f := range strings.Split(list, ",") {
+		f = strings.TrimSpace(f)
+		if f == "" {
+			continue
+		}
+		step, err := strconv.Atoi(f)
+		if err != nil || step < 0 {
+			return nil, fmt.Errorf("invalid step %q", f)
+		}
+		steps[step] = true
+	}
+	return steps, nil
+}
+
+type countResult struct {
+	total  uint64
+	unique uint64
+}
+
+func symmetryCount(pos []int) uint64 {
+	n := len(pos)
+	transforms := []func(r, c int) (int, int){
+		func(r, c int) (int, int) { return c, n - 1 - r },
+		func(r, c int) (int, int) { return n - 1 - r, n - 1 - c },
+		func(r, c int) (int, int) { return n - 1 - c, r },
+		func(r, c int) (int, int) { return r, n - 1 - c },
+		func(r, c int) (int, int) { return n - 1 - r, c },
+		func(r, c int) (int, int) { return c, r },
+		func(r, c int) (int, int) { return n - 1 - c, n - 1 - r },
+	}
+	stab := uint64(1)
+	for _, t := range transforms {
+		fixed := true
+		for r, c := range pos {
+			tr, tc := t(r, c)
+			if pos[tr] != tc {
+				fixed = false
+				break
+			}
+		}
+		if fixed {
+			stab++
+		}
+	}
+	return stab
+}
+
+// maxCountN is the largest board the uint64 bitmasks of countFrom can represent.
+const maxCountN = 64
+
+func countFrom(n, firstCol int) countResult {
+	all := uint64(1)<<uint(n) - 1
+	pos := make([]int, n)
+	pos[0] = firstCol
+	var res countResult
+	var place func(row int, cols, ld, rd uint64)
+	place = func(row int, cols, ld, rd uint64) {
+		if row == n {
+			res.total++
+			res.unique += symmetryCount(pos)
+			return
+		}
+		free := all &^ (cols | ld | rd)
+		for free != 0 {
+			bit := free & -free
+			free ^= bit
+			pos[row] = bits.TrailingZeros64(bit)
+			place(row+1, cols|bit, (ld|bit)<<1&all, (rd|bit)>>1)
+		}
+	}
+	bit := uint64(1) << uint(firstCol)
+	place(1, bit, bit<<1&all, bit>>1)
+	return res
+}
+
+func countSolutions(n, workers int) countResult {
+	if n < 1 || n > maxCountN {
+		return countResult{}
+	}
+	if workers < 1 {
+		workers = 1
+	}
+	half := n / 2
+	firstCols := make([]int, 0, half+1)
+	for c := 0; c < half; c++ {
+		firstCols = append(firstCols, c)
+	}
+	if n%2 == 1 {
+		firstCols = append(firstCols, half)
+	}
+
+	results := make([]countResult, len(firstCols))
+	sem := make(chan struct{}, workers)
+	var wg sync.WaitGroup
+	for i, c := range firstCols {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i, c int) {
+			defer wg.Done()
+			results[i] = countFrom(n, c)
+			<-sem
+		}(i, c)
+	}
+	wg.Wait()
+
+	var total countResult
+	unique8 := uint64(0)
+	for i, c := range firstCols {
+		weight := uint64(2)
+		if n%2 == 1 && c == half {
+			weight = 1
+		}
+		total.total += weight * results[i].total
+		unique8 += weight * results[i].unique
+	}
+	total.unique = unique8 / 8
+	return total
+}
+
+func compareStrategies(n int, v variant, obstacles []cell, cfg searchConfig, seed int64) {
+	for _, name := range strategyOrder {
+		cfg.strategy = strategyNames[name]
+		start := time.Now()
+		s := newSolver(n, v, obstacles, rand.New(rand.NewSource(seed)))
+		s.search = cfg
+		sol := s.solve()
+		elapsed := time.Since(start)
+		fmt.Printf("%-12s solved=%-5t steps=%-8d time=%dms\n", name, sol != nil, s.steps, elapsed.Milliseconds())
+	}
+}
+
+func writeTrace(t *tracer, csvPath, heatmapPattern string, n int, obstacles []cell) {
+	if csvPath != "" {
+		if err := t.writeCSV(csvPath); err != nil {
+			fmt.Fprintln(os.Stderr, "error writing trace:", err)
+		}
+	}
+	if heatmapPattern == "" {
+		return
+	}
+	blocked := obstacleSet(obstacles)
+	for _, h := range t.heatmaps {
+		img := boardImage{n: n, queens: h.queens, blocked: blocked, heat: h.cells}
+		if err := writeBoardImage(fmt.Sprintf(heatmapPattern, h.step), img); err != nil {
+			fmt.Fprintln(os.Stderr, "error writing heatmap:", err)
+		}
+	}
+	missing := make([]int, 0, len(t.snapshots))
+	for step := range t.snapshots {
+		missing = append(missing, step)
+	}
+	sort.Ints(missing)
+	for _, step := range missing {
+		fmt.Fprintf(os.Stderr, "step %d was not reached, no heatmap written\n", step)
+	}
+}
+
+func runVerify(r io.Reader) int {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		fmt.Println("Invalid input:", err)
+		return 2
+	}
+	text := strings.TrimSpace(string(data))
+	if !strings.HasPrefix(text, "[") || !strings.HasSuffix(text, "]") {
+		fmt.Println("Invalid input: expected [r0, r1, ...]")
+		return 2
+	}
+	var sol []int
+	if body := strings.TrimSpace(text[1 : len(text)-1]); body != "" {
+		for _, f := range strings.Split(body, ",") {
+			v, err := strconv.Atoi(strings.TrimSpace(f))
+			if err != nil {
+				fmt.Println("Invalid input:", err)
+				return 2
+			}
+			sol = append(sol, v)
+		}
+	}
+	if msg := findAttack(sol); msg != "" {
+		fmt.Println("INVALID:", msg)
+		return 1
+	}
+	fmt.Printf("OK: %d non-attacking queens\n", len(sol))
+	return 0
+}
+
+func findAttack(sol []int) string {
+	n := len(sol)
+	if n == 0 {
+		return "empty solution"
+	}
+	byRow := make(map[int]int, n)
+	byDiff := make(map[int]int, n)
+	bySum := make(map[int]int, n)
+	for col, row := range sol {
+		if row < 0 || row >= n {
+			return fmt.Sprintf("column %d has row %d outside the board", col, row)
+		}
+		if other, ok := byRow[row]; ok {
+			return fmt.Sprintf("columns %d and %d share row %d", other, col, row)
+		}
+		if other, ok := byDiff[row-col]; ok {
+			return fmt.Sprintf("columns %d and %d share a diagonal", other, col)
+		}
+		if other, ok := bySum[row+col]; ok {
+			return fmt.Sprintf("columns %d and %d share an anti-diagonal", other, col)
+		}
+		byRow[row] = col
+		byDiff[row-col] = col
+		bySum[row+col] = col
+	}
+	return ""
+}
+
+const maxHeatmapN = 200
+
+type boardImage struct {
+	n       int
+	queens  []int
+	blocked map[cell]bool
+	heat    []int
+}
+
+func (b boardImage) cellSize() int {
+	size := 800 / b.n
+	if size > 40 {
+		size = 40
+	}
+	if size < 1 {
+		size = 1
+	}
+	return size
+}
+
+func (b boardImage) maxHeat() int {
+	m := 0
+	for _, h := range b.heat {
+		if h > m {
+			m = h
+		}
+	}
+	return m
+}
+
+func (b boardImage) cellColor(r, c, maxHeat int) color.RGBA {
+	if b.blocked[cell{r, c}] {
+		return color.RGBA{0x66, 0x66, 0x66, 0xff}
+	}
+	if b.heat != nil {
+		if maxHeat == 0 {
+			return color.RGBA{0xff, 0xff, 0xff, 0xff}
+		}
+		shade := uint8(255 - 255*b.heat[r*b.n+c]/maxHeat)
+		return color.RGBA{0xff, shade, shade, 0xff}
+	}
+	if (r+c)%2 == 0 {
+		return color.RGBA{0xf0, 0xd9, 0xb5, 0xff}
+	}
+	return color.RGBA{0xb5, 0x88, 0x63, 0xff}
+}
+
+func hexColor(c color.RGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
+
+func (b boardImage) writeSVG(w io.Writer) error {
+	cs := b.cellSize()
+	size := cs * b.n
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "<svg xmlns=\"http://www.w3.org/2000/svg\" width=\"%d\" height=\"%d\" viewBox=\"0 0 %d %d\">\n", size, size, size, size)
+	if b.heat == nil {
+		fmt.Fprintf(bw, "<defs><pattern id=\"board\" width=\"%d\" height=\"%d\" patternUnits=\"userSpaceOnUse\">", 2*cs, 2*cs)
+		fmt.Fprintf(bw, "<rect width=\"%d\" height=\"%d\" fill=\"%s\"/>", 2*cs, 2*cs, hexColor(b.cellColor(0, 0, 0)))
+		fmt.Fprintf(bw, "<rect x=\"%d\" width=\"%d\" height=\"%d\" fill=\"%s\"/>", cs, cs, cs, hexColor(b.cellColor(0, 1, 0)))
+		fmt.Fprintf(bw, "<rect y=\"%d\" width=\"%d\" height=\"%d\" fill=\"%s\"/>", cs, cs, cs, hexColor(b.cellColor(1, 0, 0)))
+		fmt.Fprintf(bw, "</pattern></defs>\n<rect width=\"%d\" height=\"%d\" fill=\"url(#board)\"/>\n", size, size)
+		for o := range b.blocked {
+			fmt.Fprintf(bw, "<rect x=\"%d\" y=\"%d\" width=\"%d\" height=\"%d\" fill=\"%s\"/>\n", o.col*cs, o.row*cs, cs, cs, hexColor(b.cellColor(o.row, o.col, 0)))
+		}
+	} else {
+		maxHeat := b.maxHeat()
+		for r := 0; r < b.n; r++ {
+			for c := 0; c < b.n; c++ {
+				fmt.Fprintf(bw, "<rect x=\"%d\" y=\"%d\" width=\"%d\" height=\"%d\" fill=\"%s\"/>\n", c*cs, r*cs, cs, cs, hexColor(b.cellColor(r, c, maxHeat)))
+			}
+		}
+	}
+	for c, r := range b.queens {
+		if cs < 4 {
+			fmt.Fprintf(bw, "<rect x=\"%d\" y=\"%d\" width=\"%d\" height=\"%d\" fill=\"#000\"/>\n", c*cs, r*cs, cs, cs)
+		} else {
+			fmt.Fprintf(bw, "<circle cx=\"%g\" cy=\"%g\" r=\"%g\" fill=\"#000\"/>\n", (float64(c)+0.5)*float64(cs), (float64(r)+0.5)*float64(cs), 0.35*float64(cs))
+		}
+	}
+	fmt.Fprintln(bw, "</svg>")
+	return bw.Flush()
+}
+
+func (b boardImage) writePNG(w io.Writer) error {
+	cs := b.cellSize()
+	img := image.NewRGBA(image.Rect(0, 0, cs*b.n, cs*b.n))
+	maxHeat := b.maxHeat()
+	for r := 0; r < b.n; r++ {
+		for c := 0; c < b.n; c++ {
+			fill := b.cellColor(r, c, maxHeat)
+			if b.queens[c] == r {
+				fill = color.RGBA{0, 0, 0, 0xff}
+			}
+			draw.Draw(img, image.Rect(c*cs, r*cs, (c+1)*cs, (r+1)*cs), &image.Uniform{fill}, image.Point{}, draw.Src)
+		}
+	}
+	return png.Encode(w, img)
+}
+
+func writeBoardImage(path string, b boardImage) error {
+	ext := strings.ToLower(filepath.Ext(path))
+	if ext != ".svg" && ext != ".png" {
+		return fmt.Errorf("unsupported image format %q, use .svg or .png", ext)
+	}
+	if ext == ".png" && b.n > 4000 {
+		return fmt.Errorf("N=%d is too large for PNG, use .svg", b.n)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if ext == ".png" {
+		return b.writePNG(f)
+	}
+	return b.writeSVG(f)
+}
+
+func obstacleSet(obstacles []cell) map[cell]bool {
+	blocked := make(map[cell]bool, len(obstacles))
+	for _, o := range obstacles {
+		blocked[o] = true
+	}
+	return blocked
+}
+
+func readObstacles(in *bufio.Reader, n int) ([]cell, error) {
+	var k int
+	if _, err := fmt.Fscan(in, &k); err != nil {
+		return nil, fmt.Errorf("missing obstacle count: %w", err)
+	}
+	obstacles := make([]cell, 0, k)
+	for i := 0; i < k; i++ {
+		var o cell
+		if _, err := fmt.Fscan(in, &o.row, &o.col); err != nil {
+			return nil, fmt.Errorf("obstacle %d: %w", i+1, err)
+		}
+		if o.row < 0 || o.row >= n || o.col < 0 || o.col >= n {
+			return nil, fmt.Errorf("obstacle %d (%d, %d) is outside the board", i+1, o.row, o.col)
+		}
+		obstacles = append(obstacles, o)
+	}
+	return obstacles, nil
+}
+
+func printBoard(sol []int, obstacles []cell) {
+	n := len(sol)
+	blocked := obstacleSet(obstacles)
 	for r := 0; r < n; r++ {
 		for c := 0; c < n; c++ {
 			if c > 0 {
@@ -181,6 +1065,8 @@ func printBoard(sol []int) {
 			}
 			if sol[c] == r {
 				fmt.Print("*")
+			} else if blocked[cell{r, c}] {
+				fmt.Print("#")
 			} else {
 				fmt.Print("_")
 			}
@@ -211,21 +1097,115 @@ func nGuess(n int) int {
 }
 
 func main() {
-	rand.Seed(time.Now().UnixNano())
-
 	boardFlag := flag.Bool("board", false, "print board with '*' and '_'")
+	countFlag := flag.Bool("count", false, "count all solutions and unique ones modulo symmetry")
+	workersFlag := flag.Int("workers", runtime.NumCPU(), "goroutines used by -count and -portfolio")
+	variantFlag := flag.String("variant", "queen", "piece variant: queen, toroidal, superqueen, amazon, king, rook")
+	obstaclesFlag := flag.Bool("obstacles", false, "read K and K blocked cells 'row col' after N")
+	portfolioFlag := flag.Bool("portfolio", false, "run -workers independent solvers concurrently, first solution wins")
+	strategyFlag := flag.String("strategy", "minconflicts", "local search: minconflicts, tabu, annealing, walk")
+	constructFlag := flag.Bool("construct", false, "build a solution with the closed-form construction instead of searching")
+	compareFlag := flag.Bool("compare", false, "run every strategy on the same N and seed and report steps and time")
+	tabuTenure := flag.Int("tabu-tenure", 10, "steps a moved column stays tabu")
+	noise := flag.Float64("noise", 0.1, "probability of a random row in the walk strategy")
+	temperature := flag.Float64("temperature", 2.0, "initial annealing temperature")
+	cooling := flag.Float64("cooling", 0.999, "annealing temperature multiplier per step")
+	seedFlag := flag.Int64("seed", 0, "random seed; 0 picks one from the clock")
+	statsFlag := flag.Bool("stats", false, "print seed, steps, restarts and time to stderr")
+	imageFlag := flag.String("image", "", "render the final board to a .svg or .png file")
+	traceFlag := flag.String("trace", "", "write the conflict count of every step to a CSV file")
+	heatmapFlag := flag.String("heatmap", "", "file pattern with %d for per-cell conflict heatmaps, e.g. heat-%d.svg")
+	heatmapSteps := flag.String("heatmap-steps", "0", "comma-separated steps at which -heatmap is rendered")
+	verifyFlag := flag.Bool("verify", false, "read a printed [...] solution from stdin and check it for attacks")
 	flag.Parse()
 
+	if *verifyFlag {
+		os.Exit(runVerify(os.Stdin))
+	}
+
+	seed := *seedFlag
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+
+	v, ok := variantNames[*variantFlag]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown variant %q\n", *variantFlag)
+		os.Exit(2)
+	}
+	st, ok := strategyNames[*strategyFlag]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown strategy %q\n", *strategyFlag)
+		os.Exit(2)
+	}
+	cfg := searchConfig{
+		strategy:    st,
+		tabuTenure:  *tabuTenure,
+		noise:       *noise,
+		temperature: *temperature,
+		cooling:     *cooling,
+	}
+	if *countFlag && v != variantQueen {
+		fmt.Fprintln(os.Stderr, "-count supports only the queen variant")
+		os.Exit(2)
+	}
+	if *obstaclesFlag && (*countFlag || v == variantToroidal) {
+		fmt.Fprintln(os.Stderr, "-obstacles cannot be combined with -count or the toroidal variant")
+		os.Exit(2)
+	}
+	if *constructFlag && (v != variantQueen || *obstaclesFlag) {
+		fmt.Fprintln(os.Stderr, "-construct supports only the queen variant without obstacles")
+		os.Exit(2)
+	}
+	if (*traceFlag != "" || *heatmapFlag != "") && (*portfolioFlag || *constructFlag || *compareFlag) {
+		fmt.Fprintln(os.Stderr, "-trace and -heatmap need a single search run")
+		os.Exit(2)
+	}
+	if *heatmapFlag != "" && !strings.Contains(*heatmapFlag, "%d") {
+		fmt.Fprintf(os.Stderr, "-heatmap pattern must contain %%d\n")
+		os.Exit(2)
+	}
+	snapshots, err := parseSteps(*heatmapSteps)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "-heatmap-steps:", err)
+		os.Exit(2)
+	}
+
 	in := bufio.NewReader(os.Stdin)
 	var n int
 	if _, err := fmt.Fscan(in, &n); err != nil {
 		return
 	}
+	var obstacles []cell
+	if *obstaclesFlag {
+		var err error
+		obstacles, err = readObstacles(in, n)
+		if err != nil {
+			fmt.Println("Invalid input:", err)
+			return
+		}
+	}
 
 	timeOnly := os.Getenv("FMI_TIME_ONLY") == "1"
 	start := time.Now()
 
-	if n == 2 || n == 3 {
+	if *countFlag {
+		if n < 1 || n > maxCountN {
+			fmt.Fprintf(os.Stderr, "-count supports 1 <= N <= %d\n", maxCountN)
+			os.Exit(2)
+		}
+		res := countSolutions(n, *workersFlag)
+		elapsed := time.Since(start)
+		if timeOnly {
+			fmt.Printf("# TIMES_MS: alg=%d\n", elapsed.Milliseconds())
+			return
+		}
+		fmt.Printf("solutions: %d\n", res.total)
+		fmt.Printf("unique: %d\n", res.unique)
+		return
+	}
+
+	if len(obstacles) == 0 && !v.solvable(n) || n == 1 && len(obstacles) > 0 {
 		elapsed := time.Since(start)
 		if timeOnly {
 			fmt.Printf("# TIMES_MS: alg=%d\n", elapsed.Milliseconds())
@@ -244,9 +1224,51 @@ func main() {
 		return
 	}
 
-	s := newSolver(n)
-	sol := s.solve()
+	if *compareFlag {
+		compareStrategies(n, v, obstacles, cfg, seed)
+		return
+	}
+
+	var sol []int
+	steps, restarts := 0, 0
+	searched := true
+	if *constructFlag {
+		searched = false
+		sol = construct(n)
+		if !verifySolution(sol, v) {
+			fmt.Fprintf(os.Stderr, "construction for N=%d failed verification\n", n)
+			sol = nil
+		}
+	} else if *portfolioFlag {
+		res := solvePortfolio(n, v, obstacles, cfg, *workersFlag, seed)
+		sol, steps, restarts = res.sol, res.steps, res.restarts
+		if sol != nil {
+			fmt.Fprintf(os.Stderr, "worker %d of %d won after %d steps\n", res.worker, res.workers, res.steps)
+		}
+	} else {
+		s := newSolver(n, v, obstacles, rand.New(rand.NewSource(seed)))
+		s.search = cfg
+		if *traceFlag != "" || *heatmapFlag != "" {
+			if *heatmapFlag != "" && n > maxHeatmapN {
+				fmt.Fprintf(os.Stderr, "-heatmap supports N <= %d\n", maxHeatmapN)
+				os.Exit(2)
+			}
+			s.trace = &tracer{snapshots: map[int]bool{}}
+			if *heatmapFlag != "" {
+				s.trace.snapshots = snapshots
+			}
+		}
+		sol = s.solve()
+		steps, restarts = s.steps, s.restarts
+		if s.trace != nil {
+			writeTrace(s.trace, *traceFlag, *heatmapFlag, n, obstacles)
+		}
+	}
 	elapsed := time.Since(start)
+	if *statsFlag || searched && sol == nil {
+		fmt.Fprintf(os.Stderr, "seed=%d steps=%d restarts=%d time=%dms solved=%t\n",
+			seed, steps, restarts, elapsed.Milliseconds(), sol != nil)
+	}
 	if sol == nil {
 		if timeOnly {
 			fmt.Printf("# TIMES_MS: alg=%d\n", elapsed.Milliseconds())
@@ -256,11 +1278,18 @@ func main() {
 		return
 	}
 
+	if *imageFlag != "" {
+		img := boardImage{n: n, queens: sol, blocked: obstacleSet(obstacles)}
+		if err := writeBoardImage(*imageFlag, img); err != nil {
+			fmt.Fprintln(os.Stderr, "error writing image:", err)
+		}
+	}
+
 	if timeOnly {
 		fmt.Printf("# TIMES_MS: alg=%d\n", elapsed.Milliseconds())
 	} else {
 		if *boardFlag {
-			printBoard(sol)
+			printBoard(sol, obstacles)
 		} else {
 			printArray(sol)
 		}
@@ -2,11 +2,24 @@ package main
 
 import (
 	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
 	"flag"
 	"fmt"
+	"hash/fnv"
+	"io"
+	"math"
+	"math/bits"
 	"math/rand"
 	"os"
+	"os/signal"
+	"path/filepath"
 	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -14,70 +27,347 @@ type Item struct {
 	Index  int
 	Weight int
 	Value  int
+	Extra  []int // further constraint dimensions (volume, cost, ...)
+	Values []int // all value criteria in multi-objective mode, Values[0] == Value
+}
+
+type Genome []uint64
+
+func NewGenome(n int) Genome {
+	return make(Genome, (n+63)/64)
+}
+
+func (g Genome) Has(i int) bool {
+	return g[i>>6]&(1<<(uint(i)&63)) != 0
+}
+
+func (g Genome) Set(i int) {
+	g[i>>6] |= 1 << (uint(i) & 63)
+}
+
+func (g Genome) Clear(i int) {
+	g[i>>6] &^= 1 << (uint(i) & 63)
+}
+
+func (g Genome) Clone() Genome {
+	c := make(Genome, len(g))
+	copy(c, g)
+	return c
+}
+
+func (g Genome) Count() int {
+	count := 0
+	for _, w := range g {
+		count += bits.OnesCount64(w)
+	}
+	return count
+}
+
+func (g Genome) ForEach(fn func(i int)) {
+	for wi, w := range g {
+		for w != 0 {
+			fn(wi<<6 + bits.TrailingZeros64(w))
+			w &= w - 1
+		}
+	}
 }
 
 type Candidate struct {
-	Genes []int
-	Value float64
+	Genes    Genome
+	Value    float64
+	Feasible bool
 }
 
 type KnapsackSolver struct {
 	Items          []Item
 	Capacity       int
+	ExtraCapacity  []int
 	PopSize        int
 	MaxGenerations int
 	Population     []Candidate
 	MutationRate   float64
 	BestValues     []float64
+	RecordStats    bool
+	Stats          []GenerationStats
+	started        time.Time
+	Repair         bool
+	Variant        Variant
+	Rng            *rand.Rand
+	Selection      string
+	Crossover      string
+	ratioOrder     []int
+
+	StagnationLimit  int
+	AdaptiveMutation bool
+	RestartElites    bool
+	TargetValue      float64
+	TimeBudget       time.Duration
+	StopReason       string
+	bestSoFar        float64
+	stagnant         int
+	baseMutation     float64
+	sharedStagnation bool // an IslandModel judges stagnation on its overall best
+
+	Interrupt          *atomic.Bool
+	CheckpointInterval int
+	OnCheckpoint       func(ks *KnapsackSolver)
 }
 
-func (c *Candidate) Evaluate(items []Item, capacity int) {
-	totalWeight := 0
-	totalValue := 0.0
-	for i, gene := range c.Genes {
-		if gene == 1 {
-			totalWeight += items[i].Weight
-			totalValue += float64(items[i].Value)
+type GAParams struct {
+	PopSize          int
+	Generations      int
+	MutationRate     float64
+	EliteSize        int
+	TournamentSize   int
+	Selection        string
+	Crossover        string
+	StagnationLimit  int
+	AdaptiveMutation bool
+	RestartElites    bool
+	TargetValue      float64
+	TimeBudget       time.Duration
+}
+
+var (
+	selectionSchemes = []string{"tournament", "roulette", "rank", "sus"}
+	crossoverKinds   = []string{"uniform", "onepoint", "twopoint"}
+)
+
+func (p GAParams) apply(ks *KnapsackSolver) {
+	if p.PopSize > 0 {
+		ks.PopSize = p.PopSize
+	}
+	if p.Generations > 0 {
+		ks.MaxGenerations = p.Generations
+	}
+	ks.MutationRate = p.MutationRate
+	ks.Selection = p.Selection
+	ks.Crossover = p.Crossover
+	ks.StagnationLimit = p.StagnationLimit
+	ks.AdaptiveMutation = p.AdaptiveMutation
+	ks.RestartElites = p.RestartElites
+	ks.TargetValue = p.TargetValue
+	ks.TimeBudget = p.TimeBudget
+}
+
+func (p GAParams) validate() error {
+	if p.PopSize < 0 || p.Generations < 0 {
+		return fmt.Errorf("population size and generations must not be negative")
+	}
+	if p.MutationRate < 0 || p.MutationRate > 1 {
+		return fmt.Errorf("mutation rate must be in [0, 1]")
+	}
+	if p.EliteSize < 0 || p.TournamentSize < 1 {
+		return fmt.Errorf("elite size must be >= 0 and tournament size >= 1")
+	}
+	if (p.AdaptiveMutation || p.RestartElites) && p.StagnationLimit <= 0 {
+		return fmt.Errorf("adaptive mutation and restarts need a stagnation limit")
+	}
+	if !contains(selectionSchemes, p.Selection) {
+		return fmt.Errorf("unknown selection %q", p.Selection)
+	}
+	if !contains(crossoverKinds, p.Crossover) {
+		return fmt.Errorf("unknown crossover %q", p.Crossover)
+	}
+	return nil
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
 		}
 	}
-	if totalWeight <= capacity {
+	return false
+}
+
+func createKnapsackSolver(items []Item, capacity int, extraCapacity []int, rng *rand.Rand) *KnapsackSolver {
+	generations := 1500
+	if len(items) > 300 {
+		generations = 1200
+	}
+	if len(items) > 1000 {
+		generations = 800
+	}
+	return &KnapsackSolver{
+		Items:          items,
+		Capacity:       capacity,
+		ExtraCapacity:  extraCapacity,
+		PopSize:        300,
+		MaxGenerations: generations,
+		MutationRate:   0.03,
+		Rng:            rng,
+	}
+}
+
+func (c *Candidate) Evaluate(items []Item, capacity int, extraCapacity []int) {
+	totalWeight := 0
+	totalValue := 0.0
+	extraLoad := make([]int, len(extraCapacity))
+	c.Genes.ForEach(func(i int) {
+		totalWeight += items[i].Weight
+		totalValue += float64(items[i].Value)
+		addLoad(extraLoad, items[i].Extra, 1)
+	})
+	c.Feasible = totalWeight <= capacity && withinCapacity(extraLoad, extraCapacity)
+	if c.Feasible {
 		c.Value = totalValue
 	} else {
 		c.Value = 0
 	}
 }
 
-func (ks *KnapsackSolver) randomFeasibleGenes() []int {
-	genes := make([]int, len(ks.Items))
-	order := rand.Perm(len(ks.Items))
-	totalWeight := 0
+func addLoad(load, extra []int, sign int) {
+	for d := range load {
+		load[d] += sign * extra[d]
+	}
+}
+
+func withinCapacity(load, capacity []int) bool {
+	for d, l := range load {
+		if l > capacity[d] {
+			return false
+		}
+	}
+	return true
+}
+
+type load struct {
+	weight int
+	value  int
+	extra  []int
+}
+
+func (l *load) add(item Item) {
+	l.weight += item.Weight
+	l.value += item.Value
+	addLoad(l.extra, item.Extra, 1)
+}
+
+func (l *load) remove(item Item) {
+	l.weight -= item.Weight
+	l.value -= item.Value
+	addLoad(l.extra, item.Extra, -1)
+}
+
+func (ks *KnapsackSolver) newLoad() load {
+	return load{extra: make([]int, len(ks.ExtraCapacity))}
+}
+
+func (ks *KnapsackSolver) loadOf(genes Genome) load {
+	l := ks.newLoad()
+	for wi, w := range genes {
+		for w != 0 {
+			it := &ks.Items[wi<<6+bits.TrailingZeros64(w)]
+			l.weight += it.Weight
+			l.value += it.Value
+			addLoad(l.extra, it.Extra, 1)
+			w &= w - 1
+		}
+	}
+	return l
+}
+
+func (ks *KnapsackSolver) feasible(l load) bool {
+	return l.weight <= ks.Capacity && withinCapacity(l.extra, ks.ExtraCapacity)
+}
+
+func (ks *KnapsackSolver) fits(l load, item Item) bool {
+	if l.weight+item.Weight > ks.Capacity {
+		return false
+	}
+	for d, e := range l.extra {
+		if e+item.Extra[d] > ks.ExtraCapacity[d] {
+			return false
+		}
+	}
+	return true
+}
+
+func (ks *KnapsackSolver) randomFeasibleGenes() Genome {
+	genes := NewGenome(len(ks.Items))
+	order := ks.Rng.Perm(len(ks.Items))
+	l := ks.newLoad()
 	for _, idx := range order {
-		if totalWeight+ks.Items[idx].Weight <= ks.Capacity {
-			genes[idx] = 1
-			totalWeight += ks.Items[idx].Weight
+		if ks.fits(l, ks.Items[idx]) {
+			genes.Set(idx)
+			l.add(ks.Items[idx])
 		}
 	}
 	return genes
 }
 
+type GenerationStats struct {
+	Island      int     `json:"island"`
+	Generation  int     `json:"generation"`
+	Best        float64 `json:"best"`
+	Mean        float64 `json:"mean"`
+	Worst       float64 `json:"worst"`
+	Feasible    float64 `json:"feasible_fraction"`
+	Diversity   float64 `json:"diversity"`
+	ElapsedSecs float64 `json:"elapsed_s"`
+}
+
+// diversity is the mean Hamming distance over all pairs of candidates,
+// computed from the number of candidates that carry each item.
+func (ks *KnapsackSolver) diversity() float64 {
+	p := len(ks.Population)
+	if p < 2 {
+		return 0
+	}
+	carriers := make([]int, ks.genomeLen())
+	for _, c := range ks.Population {
+		c.Genes.ForEach(func(i int) {
+			carriers[i]++
+		})
+	}
+	sum := 0.0
+	for _, k := range carriers {
+		sum += float64(k) * float64(p-k)
+	}
+	return 2 * sum / (float64(p) * float64(p-1))
+}
+
+func (ks *KnapsackSolver) recordStats() {
+	if !ks.RecordStats {
+		return
+	}
+	st := GenerationStats{
+		Generation:  len(ks.BestValues) - 1,
+		Best:        ks.Population[0].Value,
+		Worst:       ks.Population[len(ks.Population)-1].Value,
+		Diversity:   ks.diversity(),
+		ElapsedSecs: time.Since(ks.started).Seconds(),
+	}
+	feasible := 0
+	for _, c := range ks.Population {
+		st.Mean += c.Value
+		if c.Feasible {
+			feasible++
+		}
+	}
+	st.Mean /= float64(len(ks.Population))
+	st.Feasible = float64(feasible) / float64(len(ks.Population))
+	ks.Stats = append(ks.Stats, st)
+}
+
 func (ks *KnapsackSolver) InitPopulation() {
+	ks.started = time.Now()
 	ks.Population = make([]Candidate, ks.PopSize)
 	for i := 0; i < ks.PopSize; i++ {
-		genes := ks.randomFeasibleGenes()
-		c := Candidate{Genes: genes}
-		c.Evaluate(ks.Items, ks.Capacity)
-		ks.Population[i] = c
+		ks.Population[i] = ks.newCandidate(ks.randomGenes())
 	}
 	sort.Slice(ks.Population, func(i, j int) bool {
 		return ks.Population[i].Value > ks.Population[j].Value
 	})
 	ks.BestValues = append(ks.BestValues, ks.Population[0].Value)
+	ks.recordStats()
 }
 
 func (ks *KnapsackSolver) tournamentSelect(size int) Candidate {
-	best := ks.Population[rand.Intn(ks.PopSize)]
+	best := ks.Population[ks.Rng.Intn(ks.PopSize)]
 	for i := 1; i < size; i++ {
-		other := ks.Population[rand.Intn(ks.PopSize)]
+		other := ks.Population[ks.Rng.Intn(ks.PopSize)]
 		if other.Value > best.Value {
 			best = other
 		}
@@ -85,63 +375,210 @@ func (ks *KnapsackSolver) tournamentSelect(size int) Candidate {
 	return best
 }
 
-func crossoverTwoPoint(p1, p2 Candidate) (Candidate, Candidate) {
-	n := len(p1.Genes)
+func (ks *KnapsackSolver) selector(tournamentSize, count int) func() Candidate {
+	if ks.Selection == "tournament" || ks.Selection == "" {
+		return func() Candidate {
+			return ks.tournamentSelect(tournamentSize)
+		}
+	}
+
+	cum := make([]float64, len(ks.Population))
+	total := 0.0
+	for i, c := range ks.Population {
+		w := c.Value
+		if ks.Selection == "rank" {
+			w = float64(len(ks.Population) - i)
+		}
+		total += w
+		cum[i] = total
+	}
+	if total == 0 {
+		for i := range cum {
+			cum[i] = float64(i + 1)
+		}
+		total = float64(len(cum))
+	}
+	spin := func(r float64) Candidate {
+		return ks.Population[sort.Search(len(cum), func(i int) bool { return cum[i] > r })]
+	}
+
+	if ks.Selection != "sus" {
+		return func() Candidate {
+			return spin(ks.Rng.Float64() * total)
+		}
+	}
+	step := total / float64(count)
+	start := ks.Rng.Float64() * step
+	picks := make([]Candidate, count)
+	for i := range picks {
+		picks[i] = spin(start + float64(i)*step)
+	}
+	ks.Rng.Shuffle(count, func(i, j int) { picks[i], picks[j] = picks[j], picks[i] })
+	next := 0
+	return func() Candidate {
+		c := picks[next%count]
+		next++
+		return c
+	}
+}
+
+func crossover(rng *rand.Rand, kind string, p1, p2 Candidate, n int) (Candidate, Candidate) {
+	switch kind {
+	case "uniform":
+		return crossoverUniform(rng, p1, p2)
+	case "onepoint":
+		return crossoverOnePoint(rng, p1, p2, n)
+	}
+	return crossoverTwoPoint(rng, p1, p2, n)
+}
+
+func crossoverUniform(rng *rand.Rand, p1, p2 Candidate) (Candidate, Candidate) {
+	c1 := Candidate{Genes: make(Genome, len(p1.Genes))}
+	c2 := Candidate{Genes: make(Genome, len(p1.Genes))}
+	for w := range c1.Genes {
+		m := rng.Uint64()
+		c1.Genes[w] = p1.Genes[w]&m | p2.Genes[w]&^m
+		c2.Genes[w] = p2.Genes[w]&m | p1.Genes[w]&^m
+	}
+	return c1, c2
+}
+
+func crossoverOnePoint(rng *rand.Rand, p1, p2 Candidate, n int) (Candidate, Candidate) {
+	if n <= 1 {
+		return Candidate{Genes: p1.Genes.Clone()}, Candidate{Genes: p2.Genes.Clone()}
+	}
+	point := 1 + rng.Intn(n-1)
+	c1 := Candidate{Genes: make(Genome, len(p1.Genes))}
+	c2 := Candidate{Genes: make(Genome, len(p1.Genes))}
+	for w := range c1.Genes {
+		m := rangeMask(w, 0, point)
+		c1.Genes[w] = p1.Genes[w]&m | p2.Genes[w]&^m
+		c2.Genes[w] = p2.Genes[w]&m | p1.Genes[w]&^m
+	}
+	return c1, c2
+}
+
+func rangeMask(word, from, to int) uint64 {
+	lo, hi := word<<6, word<<6+64
+	if to <= lo || from >= hi {
+		return 0
+	}
+	m := ^uint64(0)
+	if from > lo {
+		m &= ^uint64(0) << uint(from-lo)
+	}
+	if to < hi {
+		m &= ^uint64(0) >> uint(hi-to)
+	}
+	return m
+}
+
+func crossoverTwoPoint(rng *rand.Rand, p1, p2 Candidate, n int) (Candidate, Candidate) {
 	if n <= 1 {
-		return p1, p2
+		return Candidate{Genes: p1.Genes.Clone()}, Candidate{Genes: p2.Genes.Clone()}
 	}
-	p1i := rand.Intn(n)
-	p2i := rand.Intn(n)
+	p1i := rng.Intn(n)
+	p2i := rng.Intn(n)
 	if p1i > p2i {
 		p1i, p2i = p2i, p1i
 	}
 	if p1i == p2i {
-		return p1, p2
+		return Candidate{Genes: p1.Genes.Clone()}, Candidate{Genes: p2.Genes.Clone()}
 	}
-	c1 := Candidate{Genes: make([]int, n)}
-	c2 := Candidate{Genes: make([]int, n)}
-
-	copy(c1.Genes[p1i:p2i], p1.Genes[p1i:p2i])
-	copy(c2.Genes[p1i:p2i], p2.Genes[p1i:p2i])
-
-	for i := 0; i < n; i++ {
-		if i < p1i || i >= p2i {
-			c1.Genes[i] = p2.Genes[i]
-			c2.Genes[i] = p1.Genes[i]
-		}
+	c1 := Candidate{Genes: make(Genome, len(p1.Genes))}
+	c2 := Candidate{Genes: make(Genome, len(p1.Genes))}
+	for w := range c1.Genes {
+		m := rangeMask(w, p1i, p2i)
+		c1.Genes[w] = p1.Genes[w]&m | p2.Genes[w]&^m
+		c2.Genes[w] = p2.Genes[w]&m | p1.Genes[w]&^m
 	}
 	return c1, c2
 }
 
-func (ks *KnapsackSolver) totalWeight(genes []int) int {
-	sum := 0
-	for i, g := range genes {
-		if g == 1 {
-			sum += ks.Items[i].Weight
-		}
+// nextMutation skips ahead by a geometrically distributed gap, which flips
+// every gene with probability MutationRate without drawing a number per gene.
+func (ks *KnapsackSolver) nextMutation(i int) int {
+	n := ks.genomeLen()
+	switch {
+	case ks.MutationRate <= 0:
+		return n
+	case ks.MutationRate >= 1:
+		return i + 1
 	}
-	return sum
+	gap := math.Log(1-ks.Rng.Float64()) / math.Log(1-ks.MutationRate)
+	if gap >= float64(n) {
+		return n
+	}
+	return i + 1 + int(gap)
 }
 
 func (ks *KnapsackSolver) mutateCandidate(c Candidate) Candidate {
-	currWeight := ks.totalWeight(c.Genes)
-	for i := range c.Genes {
-		if rand.Float64() < ks.MutationRate {
-			if c.Genes[i] == 0 {
-				if currWeight+ks.Items[i].Weight <= ks.Capacity {
-					c.Genes[i] = 1
-					currWeight += ks.Items[i].Weight
-				}
-			} else {
-				c.Genes[i] = 0
-				currWeight -= ks.Items[i].Weight
+	if ks.Variant != nil {
+		ks.Variant.Mutate(ks, c.Genes)
+		ks.Variant.Evaluate(ks, &c)
+		return c
+	}
+	l := ks.loadOf(c.Genes)
+	for i := ks.nextMutation(-1); i < len(ks.Items); i = ks.nextMutation(i) {
+		if !c.Genes.Has(i) {
+			if ks.fits(l, ks.Items[i]) {
+				c.Genes.Set(i)
+				l.add(ks.Items[i])
 			}
+		} else {
+			c.Genes.Clear(i)
+			l.remove(ks.Items[i])
 		}
 	}
-	c.Evaluate(ks.Items, ks.Capacity)
+	if ks.Repair {
+		ks.repair(c.Genes, &l)
+	}
+	c.Value = 0
+	c.Feasible = ks.feasible(l)
+	if c.Feasible {
+		c.Value = float64(l.value)
+	}
 	return c
 }
 
+func (ks *KnapsackSolver) byRatioOrder() []int {
+	if ks.ratioOrder != nil {
+		return ks.ratioOrder
+	}
+	density := make([]float64, len(ks.Items))
+	for i, it := range ks.Items {
+		size := float64(it.Weight) / float64(ks.Capacity)
+		for d, w := range it.Extra {
+			size += float64(w) / float64(ks.ExtraCapacity[d])
+		}
+		density[i] = float64(it.Value) / size
+	}
+	ks.ratioOrder = make([]int, len(ks.Items))
+	for i := range ks.ratioOrder {
+		ks.ratioOrder[i] = i
+	}
+	sort.SliceStable(ks.ratioOrder, func(a, b int) bool {
+		return density[ks.ratioOrder[a]] > density[ks.ratioOrder[b]]
+	})
+	return ks.ratioOrder
+}
+
+func (ks *KnapsackSolver) repair(genes Genome, l *load) {
+	order := ks.byRatioOrder()
+	for i := len(order) - 1; i >= 0 && !ks.feasible(*l); i-- {
+		if idx := order[i]; genes.Has(idx) {
+			genes.Clear(idx)
+			l.remove(ks.Items[idx])
+		}
+	}
+	for _, idx := range order {
+		if !genes.Has(idx) && ks.fits(*l, ks.Items[idx]) {
+			genes.Set(idx)
+			l.add(ks.Items[idx])
+		}
+	}
+}
+
 func (ks *KnapsackSolver) evolveStep(eliteSize, tournamentSize int) {
 	sort.Slice(ks.Population, func(i, j int) bool {
 		return ks.Population[i].Value > ks.Population[j].Value
@@ -152,10 +589,11 @@ func (ks *KnapsackSolver) evolveStep(eliteSize, tournamentSize int) {
 		next = append(next, ks.Population[i])
 	}
 
+	pick := ks.selector(tournamentSize, (ks.PopSize-len(next)+1)/2*2)
 	for len(next) < ks.PopSize {
-		p1 := ks.tournamentSelect(tournamentSize)
-		p2 := ks.tournamentSelect(tournamentSize)
-		c1, c2 := crossoverTwoPoint(p1, p2)
+		p1 := pick()
+		p2 := pick()
+		c1, c2 := crossover(ks.Rng, ks.Crossover, p1, p2, ks.genomeLen())
 		c1 = ks.mutateCandidate(c1)
 		if len(next) < ks.PopSize {
 			next = append(next, c1)
@@ -171,77 +609,2223 @@ func (ks *KnapsackSolver) evolveStep(eliteSize, tournamentSize int) {
 	})
 	ks.Population = next
 	ks.BestValues = append(ks.BestValues, ks.Population[0].Value)
+	ks.recordStats()
 }
 
+const (
+	maxAdaptiveMutation = 0.5
+	stagnationStop      = "no improvement within the stagnation limit"
+)
+
 func (ks *KnapsackSolver) Run(eliteSize, tournamentSize int) Candidate {
-	for gen := 0; gen < ks.MaxGenerations; gen++ {
+	for gen := len(ks.BestValues) - 1; gen < ks.MaxGenerations && !ks.shouldStop(); gen++ {
 		ks.evolveStep(eliteSize, tournamentSize)
+		ks.handleStagnation(eliteSize)
+		if ks.OnCheckpoint != nil && ks.CheckpointInterval > 0 && (gen+1)%ks.CheckpointInterval == 0 {
+			ks.OnCheckpoint(ks)
+		}
 	}
 	return ks.Population[0]
 }
 
-func main() {
-	rand.NewSource(time.Now().UnixNano())
+func (ks *KnapsackSolver) shouldStop() bool {
+	if ks.StopReason != "" {
+		return true
+	}
+	switch {
+	case ks.Interrupt != nil && ks.Interrupt.Load():
+		ks.StopReason = "interrupted"
+	case ks.TargetValue > 0 && ks.Population[0].Value >= ks.TargetValue:
+		ks.StopReason = "target value reached"
+	case ks.TimeBudget > 0 && time.Since(ks.started) >= ks.TimeBudget:
+		ks.StopReason = "time budget exhausted"
+	}
+	return ks.StopReason != ""
+}
 
-	measureTime := flag.Bool("time", false, "print elapsed time to stderr")
-	flag.Parse()
+func (ks *KnapsackSolver) handleStagnation(eliteSize int) {
+	if ks.StagnationLimit <= 0 || ks.sharedStagnation {
+		return
+	}
+	if ks.baseMutation == 0 {
+		ks.baseMutation = ks.MutationRate
+	}
+	if best := ks.Population[0].Value; best > ks.bestSoFar {
+		ks.bestSoFar = best
+		ks.stagnant = 0
+		ks.MutationRate = ks.baseMutation
+		return
+	}
+	ks.stagnant++
+	if ks.stagnant < ks.StagnationLimit {
+		return
+	}
+	ks.stagnant = 0
+	if !ks.AdaptiveMutation && !ks.RestartElites {
+		ks.StopReason = stagnationStop
+		return
+	}
+	if ks.AdaptiveMutation {
+		ks.MutationRate = math.Min(2*ks.MutationRate, maxAdaptiveMutation)
+	}
+	if ks.RestartElites {
+		for i := eliteSize; i < len(ks.Population); i++ {
+			ks.Population[i] = ks.newCandidate(ks.randomGenes())
+		}
+		sort.Slice(ks.Population, func(i, j int) bool {
+			return ks.Population[i].Value > ks.Population[j].Value
+		})
+	}
+}
 
-	start := time.Now()
+type IslandModel struct {
+	Islands           []*KnapsackSolver
+	Topology          string
+	MigrationInterval int
+	MigrationSize     int
+	BestValues        []float64
+	stagnated         bool
+	bestSoFar         float64
+	stagnant          int
+	checked           int // generations already checked for stagnation
+}
 
-	in := bufio.NewReader(os.Stdin)
-	var capacity, n int
-	if _, err := fmt.Fscan(in, &capacity, &n); err != nil {
-		return
+func (m *IslandModel) Run(eliteSize, tournamentSize int) Candidate {
+	var wg sync.WaitGroup
+	for _, island := range m.Islands {
+		wg.Add(1)
+		go func(ks *KnapsackSolver) {
+			defer wg.Done()
+			ks.InitPopulation()
+		}(island)
 	}
+	wg.Wait()
 
-	items := make([]Item, n)
-	for i := 0; i < n; i++ {
-		var w, v int
-		fmt.Fscan(in, &w, &v)
-		items[i] = Item{Index: i, Weight: w, Value: v}
+	// Without a reaction the islands stop together once the best over all of them stagnates;
+	// stopping a single island would leave it idle while it still receives migrants.
+	first := m.Islands[0]
+	limit := first.StagnationLimit
+	shared := limit > 0 && !first.AdaptiveMutation && !first.RestartElites
+	for _, island := range m.Islands {
+		island.sharedStagnation = shared
 	}
+	m.checked = 1
 
-	popSize := 300
-	generations := 1500
-	if n > 300 {
-		popSize = 200
-		generations = 1200
+	maxGenerations := first.MaxGenerations
+	interval := m.MigrationInterval
+	if interval < 1 {
+		interval = maxGenerations
 	}
-	if n > 1000 {
-		popSize = 150
-		generations = 800
+	for gen := 0; gen < maxGenerations && !m.stopped(); gen += interval {
+		epoch := interval
+		if gen+epoch > maxGenerations {
+			epoch = maxGenerations - gen
+		}
+		for _, island := range m.Islands {
+			wg.Add(1)
+			go func(ks *KnapsackSolver) {
+				defer wg.Done()
+				for g := 0; g < epoch && !ks.shouldStop(); g++ {
+					ks.evolveStep(eliteSize, tournamentSize)
+					ks.handleStagnation(eliteSize)
+				}
+			}(island)
+		}
+		wg.Wait()
+		if shared {
+			m.checkStagnation(limit)
+		}
+		if gen+epoch < maxGenerations && !m.stopped() {
+			m.migrate()
+		}
 	}
-	mutationRate := 0.03
 
-	solver := KnapsackSolver{
-		Items:          items,
-		Capacity:       capacity,
-		PopSize:        popSize,
-		MaxGenerations: generations,
-		MutationRate:   mutationRate,
+	generations := 0
+	for _, island := range m.Islands {
+		if len(island.BestValues) > generations {
+			generations = len(island.BestValues)
+		}
+	}
+	m.BestValues = make([]float64, generations)
+	best := m.Islands[0].Population[0]
+	for _, island := range m.Islands {
+		for g := range m.BestValues {
+			v := island.BestValues[len(island.BestValues)-1]
+			if g < len(island.BestValues) {
+				v = island.BestValues[g]
+			}
+			if v > m.BestValues[g] {
+				m.BestValues[g] = v
+			}
+		}
+		if island.Population[0].Value > best.Value {
+			best = island.Population[0]
+		}
 	}
+	return best
+}
 
-	solver.InitPopulation()
-	best := solver.Run(5, 3)
+// checkStagnation counts the generations since the best value over all islands last improved.
+// It runs after every epoch, so the model stops at the end of the epoch that reached the limit.
+func (m *IslandModel) checkStagnation(limit int) {
+	generations := len(m.Islands[0].BestValues)
+	for _, island := range m.Islands[1:] {
+		if len(island.BestValues) < generations {
+			generations = len(island.BestValues)
+		}
+	}
+	for ; m.checked < generations && !m.stagnated; m.checked++ {
+		best := 0.0
+		for _, island := range m.Islands {
+			best = math.Max(best, island.BestValues[m.checked])
+		}
+		if best > m.bestSoFar {
+			m.bestSoFar = best
+			m.stagnant = 0
+			continue
+		}
+		m.stagnant++
+		m.stagnated = m.stagnant >= limit
+	}
+}
 
-	if *measureTime {
-		fmt.Fprintf(os.Stderr, "Elapsed: %.6f seconds\n", time.Since(start).Seconds())
+// stopped reports whether any island reached the target, time budget or an interrupt, or the
+// best over all islands stagnated.
+func (m *IslandModel) stopped() bool {
+	return m.StopReason() != ""
+}
+
+func (m *IslandModel) StopReason() string {
+	for _, island := range m.Islands {
+		if island.StopReason != "" {
+			return island.StopReason
+		}
 	}
+	if m.stagnated {
+		return stagnationStop
+	}
+	return ""
+}
 
-	progress := solver.BestValues
-	if len(progress) == 0 {
-		fmt.Println("0")
-		fmt.Println()
-		fmt.Println("0")
-		return
+func (m *IslandModel) migrate() {
+	k := len(m.Islands)
+	emigrants := make([][]Candidate, k)
+	for i, island := range m.Islands {
+		for j := 0; j < m.MigrationSize && j < len(island.Population); j++ {
+			c := island.Population[j]
+			c.Genes = c.Genes.Clone()
+			emigrants[i] = append(emigrants[i], c)
+		}
 	}
 
-	lastIndex := len(progress) - 1
-	for i := 0; i < 10; i++ {
-		idx := i * lastIndex / 9
-		fmt.Printf("%.0f\n", progress[idx])
+	for i, island := range m.Islands {
+		var arrivals []Candidate
+		for j := range m.Islands {
+			if j == i {
+				continue
+			}
+			if m.Topology == "ring" && (j+1)%k != i {
+				continue
+			}
+			arrivals = append(arrivals, emigrants[j]...)
+		}
+		if len(arrivals) > len(island.Population) {
+			arrivals = arrivals[:len(island.Population)]
+		}
+		copy(island.Population[len(island.Population)-len(arrivals):], arrivals)
+		sort.Slice(island.Population, func(a, b int) bool {
+			return island.Population[a].Value > island.Population[b].Value
+		})
 	}
+}
 
-	fmt.Println()
-	fmt.Printf("%.0f\n", best.Value)
+const (
+	dpCellLimit = 1 << 26
+	bbNodeLimit = 20_000_000
+)
+
+// Non-dominated sorting is quadratic in the population, so NSGA-II runs fewer generations by default.
+const nsgaGenerations = 300
+
+type MOCandidate struct {
+	Genes      Genome
+	Objectives []float64
+	rank       int
+	crowding   float64
+}
+
+// NSGA2 evolves a population towards the Pareto front of items with several value criteria,
+// reusing the solver's genome operators. Criteria marked in Minimize (e.g. risk) are minimised.
+type NSGA2 struct {
+	ks         *KnapsackSolver
+	Minimize   []bool
+	Population []MOCandidate
+}
+
+func (m *NSGA2) evaluate(genes Genome) MOCandidate {
+	ks := m.ks
+	l := ks.loadOf(genes)
+	for !ks.feasible(l) {
+		idx := ks.Rng.Intn(len(ks.Items))
+		for !genes.Has(idx) {
+			idx = (idx + 1) % len(ks.Items)
+		}
+		genes.Clear(idx)
+		l.remove(ks.Items[idx])
+	}
+	c := MOCandidate{Genes: genes, Objectives: make([]float64, len(m.Minimize))}
+	genes.ForEach(func(i int) {
+		for k, v := range ks.Items[i].Values {
+			c.Objectives[k] += float64(v)
+		}
+	})
+	return c
+}
+
+// compare returns 1 if a dominates b, -1 if b dominates a and 0 otherwise.
+func (m *NSGA2) compare(a, b MOCandidate) int {
+	aBetter, bBetter := false, false
+	for k, min := range m.Minimize {
+		x, y := a.Objectives[k], b.Objectives[k]
+		if min {
+			x, y = y, x
+		}
+		if x > y {
+			aBetter = true
+		} else if x < y {
+			bBetter = true
+		}
+	}
+	switch {
+	case aBetter && !bBetter:
+		return 1
+	case bBetter && !aBetter:
+		return -1
+	}
+	return 0
+}
+
+func (m *NSGA2) nonDominatedSort(pop []MOCandidate) [][]int {
+	dominatedBy := make([][]int, len(pop))
+	counts := make([]int, len(pop))
+	for i := range pop {
+		for j := i + 1; j < len(pop); j++ {
+			switch m.compare(pop[i], pop[j]) {
+			case 1:
+				dominatedBy[i] = append(dominatedBy[i], j)
+				counts[j]++
+			case -1:
+				dominatedBy[j] = append(dominatedBy[j], i)
+				counts[i]++
+			}
+		}
+	}
+	var front []int
+	for i := range pop {
+		if counts[i] == 0 {
+			front = append(front, i)
+		}
+	}
+
+	var fronts [][]int
+	for rank := 0; len(front) > 0; rank++ {
+		fronts = append(fronts, front)
+		var next []int
+		for _, i := range front {
+			pop[i].rank = rank
+			for _, j := range dominatedBy[i] {
+				if counts[j]--; counts[j] == 0 {
+					next = append(next, j)
+				}
+			}
+		}
+		front = next
+	}
+	return fronts
+}
+
+func (m *NSGA2) assignCrowding(pop []MOCandidate, front []int) {
+	for _, i := range front {
+		pop[i].crowding = 0
+	}
+	for k := range m.Minimize {
+		sort.Slice(front, func(a, b int) bool {
+			return pop[front[a]].Objectives[k] < pop[front[b]].Objectives[k]
+		})
+		lo, hi := pop[front[0]].Objectives[k], pop[front[len(front)-1]].Objectives[k]
+		pop[front[0]].crowding = math.Inf(1)
+		pop[front[len(front)-1]].crowding = math.Inf(1)
+		if hi == lo {
+			continue
+		}
+		for i := 1; i < len(front)-1; i++ {
+			pop[front[i]].crowding += (pop[front[i+1]].Objectives[k] - pop[front[i-1]].Objectives[k]) / (hi - lo)
+		}
+	}
+}
+
+func crowdedLess(a, b MOCandidate) bool {
+	if a.rank != b.rank {
+		return a.rank < b.rank
+	}
+	return a.crowding > b.crowding
+}
+
+// selectSurvivors keeps the best fronts of pop and fills the remaining places from the
+// next front by crowding distance.
+func (m *NSGA2) selectSurvivors(pop []MOCandidate, size int) []MOCandidate {
+	next := make([]MOCandidate, 0, size)
+	for _, front := range m.nonDominatedSort(pop) {
+		m.assignCrowding(pop, front)
+		if len(next)+len(front) > size {
+			sort.Slice(front, func(a, b int) bool {
+				return pop[front[a]].crowding > pop[front[b]].crowding
+			})
+			front = front[:size-len(next)]
+		}
+		for _, i := range front {
+			next = append(next, pop[i])
+		}
+		if len(next) == size {
+			break
+		}
+	}
+	return next
+}
+
+func (m *NSGA2) InitPopulation() {
+	pop := make([]MOCandidate, m.ks.PopSize)
+	for i := range pop {
+		pop[i] = m.evaluate(m.ks.randomFeasibleGenes())
+	}
+	m.Population = m.selectSurvivors(pop, len(pop))
+}
+
+func (m *NSGA2) tournament() MOCandidate {
+	a := m.Population[m.ks.Rng.Intn(len(m.Population))]
+	b := m.Population[m.ks.Rng.Intn(len(m.Population))]
+	if crowdedLess(b, a) {
+		return b
+	}
+	return a
+}
+
+func (m *NSGA2) evolveStep() {
+	ks := m.ks
+	pop := append([]MOCandidate(nil), m.Population...)
+	for len(pop) < 2*ks.PopSize {
+		p1 := Candidate{Genes: m.tournament().Genes}
+		p2 := Candidate{Genes: m.tournament().Genes}
+		c1, c2 := crossover(ks.Rng, ks.Crossover, p1, p2, len(ks.Items))
+		pop = append(pop, m.evaluate(ks.mutateCandidate(c1).Genes))
+		if len(pop) < 2*ks.PopSize {
+			pop = append(pop, m.evaluate(ks.mutateCandidate(c2).Genes))
+		}
+	}
+	m.Population = m.selectSurvivors(pop, ks.PopSize)
+}
+
+func (m *NSGA2) Run() []MOCandidate {
+	for gen := 0; gen < m.ks.MaxGenerations; gen++ {
+		m.evolveStep()
+	}
+	return m.ParetoFront()
+}
+
+// ParetoFront returns the distinct non-dominated candidates ordered by the first criterion.
+func (m *NSGA2) ParetoFront() []MOCandidate {
+	var front []MOCandidate
+	seen := make(map[string]bool)
+	for _, c := range m.Population {
+		key := fmt.Sprint(c.Objectives)
+		if c.rank == 0 && !seen[key] {
+			seen[key] = true
+			front = append(front, c)
+		}
+	}
+	sort.Slice(front, func(i, j int) bool {
+		return front[i].Objectives[0] < front[j].Objectives[0]
+	})
+	return front
+}
+
+func writeParetoFront(w io.Writer, front []MOCandidate, items []Item) error {
+	out := csv.NewWriter(w)
+	header := make([]string, 0, len(front[0].Objectives)+2)
+	for k := range front[0].Objectives {
+		header = append(header, fmt.Sprintf("value%d", k+1))
+	}
+	if err := out.Write(append(header, "weight", "items")); err != nil {
+		return err
+	}
+	for _, c := range front {
+		record := make([]string, 0, len(header)+2)
+		for _, v := range c.Objectives {
+			record = append(record, strconv.FormatFloat(v, 'f', -1, 64))
+		}
+		weight := 0
+		selected := make([]string, 0, c.Genes.Count())
+		c.Genes.ForEach(func(i int) {
+			weight += items[i].Weight
+			selected = append(selected, strconv.Itoa(i))
+		})
+		record = append(record, strconv.Itoa(weight), strings.Join(selected, " "))
+		if err := out.Write(record); err != nil {
+			return err
+		}
+	}
+	out.Flush()
+	return out.Error()
+}
+
+func parseMinimize(spec string, objectives int) ([]bool, error) {
+	minimize := make([]bool, objectives)
+	if spec == "" {
+		return minimize, nil
+	}
+	for _, field := range strings.Split(spec, ",") {
+		k, err := strconv.Atoi(strings.TrimSpace(field))
+		if err != nil || k < 1 || k > objectives {
+			return nil, fmt.Errorf("invalid criterion %q, expected 1..%d", field, objectives)
+		}
+		minimize[k-1] = true
+	}
+	return minimize, nil
+}
+
+type islandConfig struct {
+	Count             int
+	Topology          string
+	MigrationInterval int
+	MigrationSize     int
+}
+
+type gaRun struct {
+	Best        Candidate
+	Progress    []float64
+	Populations []*KnapsackSolver
+	StopReason  string
+}
+
+// runGA solves inst with one population or an island model. setup, if given, is applied to the
+// single population before it starts; it may restore a population to skip initialisation.
+func runGA(inst Instance, params GAParams, repair, recording bool, islands islandConfig, rng *rand.Rand, setup func(ks *KnapsackSolver)) gaRun {
+	newSolver := func(rng *rand.Rand) *KnapsackSolver {
+		ks := newInstanceSolver(inst, rng)
+		ks.Repair = repair
+		params.apply(ks)
+		ks.RecordStats = recording
+		return ks
+	}
+	if islands.Count > 1 {
+		model := &IslandModel{
+			Topology:          islands.Topology,
+			MigrationInterval: islands.MigrationInterval,
+			MigrationSize:     islands.MigrationSize,
+		}
+		for i := 0; i < islands.Count; i++ {
+			model.Islands = append(model.Islands, newSolver(rand.New(rand.NewSource(rng.Int63()))))
+		}
+		best := model.Run(params.EliteSize, params.TournamentSize)
+		return gaRun{Best: best, Progress: model.BestValues, Populations: model.Islands, StopReason: model.StopReason()}
+	}
+	solver := newSolver(rng)
+	if setup != nil {
+		setup(solver)
+	}
+	if solver.Population == nil {
+		solver.InitPopulation()
+	}
+	best := solver.Run(params.EliteSize, params.TournamentSize)
+	return gaRun{Best: best, Progress: solver.BestValues, Populations: []*KnapsackSolver{solver}, StopReason: solver.StopReason}
+}
+
+func percentile(sorted []float64, p float64) float64 {
+	return sorted[int(math.Ceil(p*float64(len(sorted))))-1]
+}
+
+func benchmarkRuns(inst Instance, params GAParams, repair bool, islands islandConfig, runs int, rng *rand.Rand) {
+	optimum, method, optimal := inst.knownOptimum()
+	values := make([]float64, runs)
+	times := make([]float64, runs)
+	hits := 0
+	for r := range values {
+		start := time.Now()
+		values[r] = runGA(inst, params, repair, false, islands, rand.New(rand.NewSource(rng.Int63())), nil).Best.Value
+		times[r] = time.Since(start).Seconds()
+		if optimal && values[r] >= optimum {
+			hits++
+		}
+	}
+
+	sum := 0.0
+	for _, v := range values {
+		sum += v
+	}
+	sort.Float64s(values)
+	sort.Float64s(times)
+	median := values[runs/2]
+	if runs%2 == 0 {
+		median = (values[runs/2-1] + values[runs/2]) / 2
+	}
+
+	fmt.Printf("runs: %d\n", runs)
+	if optimal {
+		fmt.Printf("optimum (%s): %.0f, reached %d/%d (%.1f%%)\n", method, optimum, hits, runs, 100*float64(hits)/float64(runs))
+	} else {
+		fmt.Println("optimum: unknown")
+	}
+	fmt.Printf("best value: mean %.1f, median %.1f, min %.0f, max %.0f\n", sum/float64(runs), median, values[0], values[runs-1])
+	fmt.Printf("time (s): p50 %.3f, p90 %.3f, p99 %.3f, max %.3f\n",
+		percentile(times, 0.5), percentile(times, 0.9), percentile(times, 0.99), times[runs-1])
+}
+
+// countingSource counts the values drawn from a seeded source, so a checkpoint can restore the
+// generator by replaying that many draws.
+type countingSource struct {
+	src   rand.Source64
+	seed  int64
+	draws uint64
+}
+
+func newCountingSource(seed int64) *countingSource {
+	return &countingSource{src: rand.NewSource(seed).(rand.Source64), seed: seed}
+}
+
+func (s *countingSource) Int63() int64 {
+	s.draws++
+	return s.src.Int63()
+}
+
+func (s *countingSource) Uint64() uint64 {
+	s.draws++
+	return s.src.Uint64()
+}
+
+func (s *countingSource) Seed(seed int64) {
+	s.src.Seed(seed)
+	s.seed, s.draws = seed, 0
+}
+
+func (s *countingSource) skip(draws uint64) {
+	for ; s.draws < draws; s.draws++ {
+		s.src.Uint64()
+	}
+}
+
+type checkpointCandidate struct {
+	Genes    []uint64 `json:"genes"`
+	Value    float64  `json:"value"`
+	Feasible bool     `json:"feasible"`
+}
+
+type Checkpoint struct {
+	Instance     string                `json:"instance"`
+	Params       GAParams              `json:"params"`
+	Repair       bool                  `json:"repair"`
+	Seed         int64                 `json:"seed"`
+	Draws        uint64                `json:"draws"`
+	Generation   int                   `json:"generation"`
+	MutationRate float64               `json:"mutation_rate"`
+	BestSoFar    float64               `json:"best_so_far"`
+	Stagnant     int                   `json:"stagnant"`
+	BaseMutation float64               `json:"base_mutation"`
+	ElapsedSecs  float64               `json:"elapsed_s"`
+	BestValues   []float64             `json:"best_values"`
+	Population   []checkpointCandidate `json:"population"`
+	Stats        []GenerationStats     `json:"stats,omitempty"`
+}
+
+func instanceFingerprint(inst Instance) string {
+	h := fnv.New64a()
+	for _, it := range inst.Items {
+		fmt.Fprint(h, it.Weight, it.Value, it.Extra, ";")
+	}
+	fmt.Fprint(h, inst.Conflicts, inst.BinCapacities)
+	return fmt.Sprintf("n=%d capacity=%d extra=%v items=%016x", len(inst.Items), inst.Capacity, inst.ExtraCapacity, h.Sum64())
+}
+
+func newCheckpoint(ks *KnapsackSolver, inst Instance, params GAParams, src *countingSource) *Checkpoint {
+	cp := &Checkpoint{
+		Instance:     instanceFingerprint(inst),
+		Params:       params,
+		Repair:       ks.Repair,
+		Seed:         src.seed,
+		Draws:        src.draws,
+		Generation:   len(ks.BestValues) - 1,
+		MutationRate: ks.MutationRate,
+		BestSoFar:    ks.bestSoFar,
+		Stagnant:     ks.stagnant,
+		BaseMutation: ks.baseMutation,
+		ElapsedSecs:  time.Since(ks.started).Seconds(),
+		BestValues:   ks.BestValues,
+		Stats:        ks.Stats,
+	}
+	for _, c := range ks.Population {
+		cp.Population = append(cp.Population, checkpointCandidate{Genes: c.Genes, Value: c.Value, Feasible: c.Feasible})
+	}
+	return cp
+}
+
+// restore puts a solver created with the checkpoint's parameters and generator back into the
+// saved state, so that Run continues exactly where the checkpoint was taken.
+func (cp *Checkpoint) restore(ks *KnapsackSolver) {
+	ks.Population = make([]Candidate, len(cp.Population))
+	for i, c := range cp.Population {
+		ks.Population[i] = Candidate{Genes: Genome(c.Genes), Value: c.Value, Feasible: c.Feasible}
+	}
+	ks.BestValues = cp.BestValues
+	ks.Stats = cp.Stats
+	ks.MutationRate = cp.MutationRate
+	ks.bestSoFar = cp.BestSoFar
+	ks.stagnant = cp.Stagnant
+	ks.baseMutation = cp.BaseMutation
+	ks.started = time.Now().Add(-time.Duration(cp.ElapsedSecs * float64(time.Second)))
+}
+
+func writeCheckpoint(path string, cp *Checkpoint) error {
+	data, err := json.Marshal(cp)
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func loadCheckpoint(path string) (*Checkpoint, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var cp Checkpoint
+	if err := json.Unmarshal(data, &cp); err != nil {
+		return nil, err
+	}
+	if len(cp.Population) == 0 || len(cp.BestValues) != cp.Generation+1 {
+		return nil, fmt.Errorf("%s: incomplete checkpoint", path)
+	}
+	return &cp, nil
+}
+
+// Variant replaces genome initialisation, mutation and evaluation for knapsack variants with
+// constraints the plain item bitset cannot express. evolveStep and the rest of the GA loop are
+// shared.
+type Variant interface {
+	GenomeLen() int
+	RandomGenes(ks *KnapsackSolver) Genome
+	Mutate(ks *KnapsackSolver, genes Genome)
+	Evaluate(ks *KnapsackSolver, c *Candidate)
+}
+
+var variantNames = []string{"", "conflict", "multiple"}
+
+func (inst Instance) variant() Variant {
+	switch {
+	case inst.Conflicts != nil:
+		return newConflictVariant(len(inst.Items), inst.Conflicts)
+	case inst.BinCapacities != nil:
+		return &multipleVariant{n: len(inst.Items), capacities: inst.BinCapacities}
+	}
+	return nil
+}
+
+func newInstanceSolver(inst Instance, rng *rand.Rand) *KnapsackSolver {
+	ks := createKnapsackSolver(inst.Items, inst.Capacity, inst.ExtraCapacity, rng)
+	ks.Variant = inst.variant()
+	return ks
+}
+
+func (ks *KnapsackSolver) genomeLen() int {
+	if ks.Variant != nil {
+		return ks.Variant.GenomeLen()
+	}
+	return len(ks.Items)
+}
+
+func (ks *KnapsackSolver) randomGenes() Genome {
+	if ks.Variant != nil {
+		return ks.Variant.RandomGenes(ks)
+	}
+	return ks.randomFeasibleGenes()
+}
+
+func (ks *KnapsackSolver) newCandidate(genes Genome) Candidate {
+	c := Candidate{Genes: genes}
+	if ks.Variant != nil {
+		ks.Variant.Evaluate(ks, &c)
+	} else {
+		c.Evaluate(ks.Items, ks.Capacity, ks.ExtraCapacity)
+	}
+	return c
+}
+
+// conflictVariant forbids taking both items of any conflict pair.
+type conflictVariant struct {
+	neighbours [][]int
+}
+
+func newConflictVariant(n int, conflicts [][2]int) *conflictVariant {
+	v := &conflictVariant{neighbours: make([][]int, n)}
+	for _, p := range conflicts {
+		v.neighbours[p[0]] = append(v.neighbours[p[0]], p[1])
+		v.neighbours[p[1]] = append(v.neighbours[p[1]], p[0])
+	}
+	return v
+}
+
+func (v *conflictVariant) GenomeLen() int {
+	return len(v.neighbours)
+}
+
+func (v *conflictVariant) clashes(genes Genome, i int) bool {
+	for _, j := range v.neighbours[i] {
+		if genes.Has(j) {
+			return true
+		}
+	}
+	return false
+}
+
+func (v *conflictVariant) RandomGenes(ks *KnapsackSolver) Genome {
+	genes := NewGenome(len(ks.Items))
+	l := ks.newLoad()
+	for _, idx := range ks.Rng.Perm(len(ks.Items)) {
+		if ks.fits(l, ks.Items[idx]) && !v.clashes(genes, idx) {
+			genes.Set(idx)
+			l.add(ks.Items[idx])
+		}
+	}
+	return genes
+}
+
+func (v *conflictVariant) Mutate(ks *KnapsackSolver, genes Genome) {
+	l := ks.loadOf(genes)
+	for i := ks.nextMutation(-1); i < len(ks.Items); i = ks.nextMutation(i) {
+		if genes.Has(i) {
+			genes.Clear(i)
+			l.remove(ks.Items[i])
+		} else if ks.fits(l, ks.Items[i]) && !v.clashes(genes, i) {
+			genes.Set(i)
+			l.add(ks.Items[i])
+		}
+	}
+}
+
+// repair drops the worst-ratio item of every conflict and of any overload, then fills up
+// greedily with items that fit and clash with nothing selected.
+func (v *conflictVariant) repair(ks *KnapsackSolver, genes Genome) {
+	order := ks.byRatioOrder()
+	l := ks.loadOf(genes)
+	for i := len(order) - 1; i >= 0; i-- {
+		if idx := order[i]; genes.Has(idx) && (!ks.feasible(l) || v.clashes(genes, idx)) {
+			genes.Clear(idx)
+			l.remove(ks.Items[idx])
+		}
+	}
+	for _, idx := range order {
+		if !genes.Has(idx) && ks.fits(l, ks.Items[idx]) && !v.clashes(genes, idx) {
+			genes.Set(idx)
+			l.add(ks.Items[idx])
+		}
+	}
+}
+
+func (v *conflictVariant) Evaluate(ks *KnapsackSolver, c *Candidate) {
+	if ks.Repair {
+		v.repair(ks, c.Genes)
+	}
+	l := ks.loadOf(c.Genes)
+	c.Feasible = ks.feasible(l)
+	c.Genes.ForEach(func(i int) {
+		if c.Feasible && v.clashes(c.Genes, i) {
+			c.Feasible = false
+		}
+	})
+	c.Value = 0
+	if c.Feasible {
+		c.Value = float64(l.value)
+	}
+}
+
+// multipleVariant packs items into several bins. Bit b*n+i of the genome puts item i into bin b;
+// an item in more than one bin makes the candidate infeasible.
+type multipleVariant struct {
+	n          int
+	capacities []int
+}
+
+func (v *multipleVariant) GenomeLen() int {
+	return v.n * len(v.capacities)
+}
+
+func (v *multipleVariant) RandomGenes(ks *KnapsackSolver) Genome {
+	genes := NewGenome(v.GenomeLen())
+	loads := make([]int, len(v.capacities))
+	for _, idx := range ks.Rng.Perm(v.n) {
+		start := ks.Rng.Intn(len(v.capacities))
+		for k := range v.capacities {
+			b := (start + k) % len(v.capacities)
+			if loads[b]+ks.Items[idx].Weight <= v.capacities[b] {
+				genes.Set(b*v.n + idx)
+				loads[b] += ks.Items[idx].Weight
+				break
+			}
+		}
+	}
+	return genes
+}
+
+// assignment returns the bin of every item (-1 for none, the first bin if several) and the
+// load of every bin.
+func (v *multipleVariant) assignment(ks *KnapsackSolver, genes Genome) ([]int, []int) {
+	bins := make([]int, v.n)
+	for i := range bins {
+		bins[i] = -1
+	}
+	loads := make([]int, len(v.capacities))
+	genes.ForEach(func(pos int) {
+		b, i := pos/v.n, pos%v.n
+		if bins[i] < 0 {
+			bins[i] = b
+		}
+		loads[b] += ks.Items[i].Weight
+	})
+	return bins, loads
+}
+
+func (v *multipleVariant) Mutate(ks *KnapsackSolver, genes Genome) {
+	bins, loads := v.assignment(ks, genes)
+	for pos := ks.nextMutation(-1); pos < v.GenomeLen(); pos = ks.nextMutation(pos) {
+		b, i := pos/v.n, pos%v.n
+		w := ks.Items[i].Weight
+		if genes.Has(pos) {
+			genes.Clear(pos)
+			loads[b] -= w
+			if bins[i] == b {
+				bins[i] = -1
+			}
+		} else if bins[i] < 0 && loads[b]+w <= v.capacities[b] {
+			genes.Set(pos)
+			loads[b] += w
+			bins[i] = b
+		}
+	}
+}
+
+// repair keeps every item in its first bin only, empties overloaded bins starting with the
+// worst-ratio items and then puts the best-ratio unassigned items into the first bin they fit.
+func (v *multipleVariant) repair(ks *KnapsackSolver, genes Genome) {
+	bins := make([]int, v.n)
+	for i := range bins {
+		bins[i] = -1
+	}
+	loads := make([]int, len(v.capacities))
+	genes.ForEach(func(pos int) {
+		b, i := pos/v.n, pos%v.n
+		if bins[i] >= 0 {
+			genes.Clear(pos)
+			return
+		}
+		bins[i] = b
+		loads[b] += ks.Items[i].Weight
+	})
+
+	order := ks.byRatioOrder()
+	for k := len(order) - 1; k >= 0; k-- {
+		i := order[k]
+		if b := bins[i]; b >= 0 && loads[b] > v.capacities[b] {
+			genes.Clear(b*v.n + i)
+			loads[b] -= ks.Items[i].Weight
+			bins[i] = -1
+		}
+	}
+	for _, i := range order {
+		if bins[i] >= 0 {
+			continue
+		}
+		for b, capacity := range v.capacities {
+			if loads[b]+ks.Items[i].Weight <= capacity {
+				genes.Set(b*v.n + i)
+				loads[b] += ks.Items[i].Weight
+				bins[i] = b
+				break
+			}
+		}
+	}
+}
+
+func (v *multipleVariant) Evaluate(ks *KnapsackSolver, c *Candidate) {
+	if ks.Repair {
+		v.repair(ks, c.Genes)
+	}
+	seen := NewGenome(v.n)
+	loads := make([]int, len(v.capacities))
+	value := 0
+	c.Feasible = true
+	c.Genes.ForEach(func(pos int) {
+		b, i := pos/v.n, pos%v.n
+		if seen.Has(i) {
+			c.Feasible = false
+		}
+		seen.Set(i)
+		loads[b] += ks.Items[i].Weight
+		value += ks.Items[i].Value
+	})
+	for b, l := range loads {
+		if l > v.capacities[b] {
+			c.Feasible = false
+		}
+	}
+	c.Value = 0
+	if c.Feasible {
+		c.Value = float64(value)
+	}
+}
+
+type ExactResult struct {
+	Value   int
+	Genes   Genome
+	Optimal bool
+	Method  string
+}
+
+func solveDP(items []Item, capacity int) ExactResult {
+	n := len(items)
+	best := make([]int, capacity+1)
+	take := make([][]bool, n)
+	for i, it := range items {
+		take[i] = make([]bool, capacity+1)
+		for w := capacity; w >= it.Weight; w-- {
+			if v := best[w-it.Weight] + it.Value; v > best[w] {
+				best[w] = v
+				take[i][w] = true
+			}
+		}
+	}
+
+	genes := NewGenome(n)
+	w := capacity
+	for i := n - 1; i >= 0; i-- {
+		if take[i][w] {
+			genes.Set(i)
+			w -= items[i].Weight
+		}
+	}
+	return ExactResult{Value: best[capacity], Genes: genes, Optimal: true, Method: "dp"}
+}
+
+// byRatio sorts items by decreasing value/weight. Zero-weight items have an infinite ratio and
+// come first; the cross-product comparison alone is not a strict weak ordering for them.
+func byRatio(items []Item) []Item {
+	sorted := make([]Item, len(items))
+	copy(sorted, items)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		a, b := sorted[i], sorted[j]
+		if a.Weight == 0 || b.Weight == 0 {
+			return a.Weight == 0 && b.Weight != 0
+		}
+		return a.Value*b.Weight > b.Value*a.Weight
+	})
+	return sorted
+}
+
+func dantzigBound(sorted []Item, from, capacity int, value float64) float64 {
+	for _, it := range sorted[from:] {
+		if it.Weight <= capacity {
+			capacity -= it.Weight
+			value += float64(it.Value)
+			continue
+		}
+		return value + float64(it.Value)*float64(capacity)/float64(it.Weight)
+	}
+	return value
+}
+
+func solveBranchAndBound(items []Item, capacity int, nodeLimit int) ExactResult {
+	sorted := byRatio(items)
+	n := len(sorted)
+	chosen := make([]bool, n)
+	bestChosen := make([]bool, n)
+	bestValue := 0
+	nodes := 0
+
+	var branch func(i, room, value int)
+	branch = func(i, room, value int) {
+		nodes++
+		if value > bestValue {
+			bestValue = value
+			copy(bestChosen, chosen)
+		}
+		if i == n || nodes > nodeLimit {
+			return
+		}
+		if dantzigBound(sorted, i, room, float64(value)) <= float64(bestValue) {
+			return
+		}
+		if sorted[i].Weight <= room {
+			chosen[i] = true
+			branch(i+1, room-sorted[i].Weight, value+sorted[i].Value)
+			chosen[i] = false
+		}
+		branch(i+1, room, value)
+	}
+	branch(0, capacity, 0)
+
+	genes := NewGenome(n)
+	for i, c := range bestChosen {
+		if c {
+			genes.Set(sorted[i].Index)
+		}
+	}
+	return ExactResult{Value: bestValue, Genes: genes, Optimal: nodes <= nodeLimit, Method: "bb"}
+}
+
+func referenceOptimum(items []Item, capacity int) (float64, string, bool) {
+	if int64(len(items))*int64(capacity+1) <= dpCellLimit {
+		res := solveDP(items, capacity)
+		return float64(res.Value), res.Method, true
+	}
+	if res := solveBranchAndBound(items, capacity, bbNodeLimit); res.Optimal {
+		return float64(res.Value), res.Method, true
+	}
+	return dantzigBound(byRatio(items), 0, capacity, 0), "lp", false
+}
+
+func compareHandling(inst Instance, params GAParams, runs int, rng *rand.Rand) {
+	optimum, _, optimal := inst.knownOptimum()
+	for _, repair := range []bool{false, true} {
+		name := "penalty"
+		if repair {
+			name = "repair"
+		}
+		sum, best, hits := 0.0, 0.0, 0
+		start := time.Now()
+		for r := 0; r < runs; r++ {
+			solver := newInstanceSolver(inst, rand.New(rand.NewSource(rng.Int63())))
+			solver.Repair = repair
+			params.apply(solver)
+			solver.InitPopulation()
+			value := solver.Run(params.EliteSize, params.TournamentSize).Value
+			sum += value
+			if value > best {
+				best = value
+			}
+			if optimal && value >= optimum {
+				hits++
+			}
+		}
+		avgTime := time.Since(start).Seconds() / float64(runs)
+		fmt.Printf("%-8s mean %.1f, best %.0f", name, sum/float64(runs), best)
+		if optimal {
+			fmt.Printf(", optimum %.0f reached %d/%d", optimum, hits, runs)
+		}
+		fmt.Printf(", %.3f s/run\n", avgTime)
+	}
+}
+
+func writeStatsLog(path string, stats []GenerationStats) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	w := bufio.NewWriter(f)
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv":
+		cw := csv.NewWriter(w)
+		cw.Write([]string{"island", "generation", "best", "mean", "worst", "feasible_fraction", "diversity", "elapsed_s"})
+		for _, st := range stats {
+			cw.Write([]string{
+				strconv.Itoa(st.Island),
+				strconv.Itoa(st.Generation),
+				strconv.FormatFloat(st.Best, 'f', -1, 64),
+				strconv.FormatFloat(st.Mean, 'f', 4, 64),
+				strconv.FormatFloat(st.Worst, 'f', -1, 64),
+				strconv.FormatFloat(st.Feasible, 'f', 4, 64),
+				strconv.FormatFloat(st.Diversity, 'f', 4, 64),
+				strconv.FormatFloat(st.ElapsedSecs, 'f', 6, 64),
+			})
+		}
+		cw.Flush()
+		if err := cw.Error(); err != nil {
+			return err
+		}
+	case ".jsonl", ".json":
+		enc := json.NewEncoder(w)
+		for _, st := range stats {
+			if err := enc.Encode(st); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("unsupported log format %q, use .csv or .jsonl", filepath.Ext(path))
+	}
+	return w.Flush()
+}
+
+func combineStats(stats []GenerationStats) []GenerationStats {
+	var combined []GenerationStats
+	counts := make([]int, 0)
+	for _, st := range stats {
+		for len(combined) <= st.Generation {
+			combined = append(combined, GenerationStats{Generation: len(combined), Best: math.Inf(-1), Worst: math.Inf(1)})
+			counts = append(counts, 0)
+		}
+		c := &combined[st.Generation]
+		c.Best = math.Max(c.Best, st.Best)
+		c.Worst = math.Min(c.Worst, st.Worst)
+		c.Mean += st.Mean
+		counts[st.Generation]++
+	}
+	for g := range combined {
+		if counts[g] > 0 {
+			combined[g].Mean /= float64(counts[g])
+		}
+	}
+	return combined
+}
+
+func writeConvergenceChart(path string, stats []GenerationStats) error {
+	if len(stats) == 0 {
+		return fmt.Errorf("no statistics recorded")
+	}
+	const (
+		width, height = 800, 400
+		left, right   = 70, 20
+		top, bottom   = 20, 40
+	)
+	lo, hi := math.Inf(1), math.Inf(-1)
+	for _, st := range stats {
+		lo = math.Min(lo, st.Worst)
+		hi = math.Max(hi, st.Best)
+	}
+	if hi == lo {
+		hi = lo + 1
+	}
+	lastGen := math.Max(1, float64(stats[len(stats)-1].Generation))
+	x := func(gen int) float64 {
+		return left + float64(gen)/lastGen*(width-left-right)
+	}
+	y := func(v float64) float64 {
+		return top + (hi-v)/(hi-lo)*(height-top-bottom)
+	}
+	polyline := func(value func(GenerationStats) float64) string {
+		pts := make([]string, len(stats))
+		for i, st := range stats {
+			pts[i] = fmt.Sprintf("%.1f,%.1f", x(st.Generation), y(value(st)))
+		}
+		return strings.Join(pts, " ")
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	w := bufio.NewWriter(f)
+	fmt.Fprintf(w, "<svg xmlns=\"http://www.w3.org/2000/svg\" width=\"%d\" height=\"%d\" font-family=\"sans-serif\" font-size=\"12\">\n", width, height)
+	fmt.Fprintf(w, "<rect width=\"%d\" height=\"%d\" fill=\"white\"/>\n", width, height)
+	fmt.Fprintf(w, "<path d=\"M%d,%d V%d H%d\" stroke=\"black\" fill=\"none\"/>\n", left, top, height-bottom, width-right)
+	fmt.Fprintf(w, "<text x=\"%d\" y=\"%d\" text-anchor=\"end\">%.0f</text>\n", left-5, top+4, hi)
+	fmt.Fprintf(w, "<text x=\"%d\" y=\"%d\" text-anchor=\"end\">%.0f</text>\n", left-5, height-bottom, lo)
+	fmt.Fprintf(w, "<text x=\"%d\" y=\"%d\">0</text>\n", left, height-bottom+15)
+	fmt.Fprintf(w, "<text x=\"%d\" y=\"%d\" text-anchor=\"end\">%.0f</text>\n", width-right, height-bottom+15, lastGen)
+	fmt.Fprintf(w, "<text x=\"%d\" y=\"%d\" text-anchor=\"middle\">generation</text>\n", (left+width-right)/2, height-bottom+30)
+	series := []struct {
+		name  string
+		color string
+		value func(GenerationStats) float64
+	}{
+		{"best", "#2a9d3a", func(st GenerationStats) float64 { return st.Best }},
+		{"mean", "#1f5fbf", func(st GenerationStats) float64 { return st.Mean }},
+		{"worst", "#c0392b", func(st GenerationStats) float64 { return st.Worst }},
+	}
+	for i, s := range series {
+		fmt.Fprintf(w, "<polyline points=\"%s\" stroke=\"%s\" fill=\"none\"/>\n", polyline(s.value), s.color)
+		fmt.Fprintf(w, "<text x=\"%d\" y=\"%d\" fill=\"%s\">%s</text>\n", width-right-60, height-bottom-50+15*i, s.color, s.name)
+	}
+	fmt.Fprintln(w, "</svg>")
+	return w.Flush()
+}
+
+func loadConfig(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	values := make(map[string]string)
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		// UseNumber keeps integers such as seeds in their literal form instead of 1e+06 notation.
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.UseNumber()
+		var raw map[string]any
+		if err := dec.Decode(&raw); err != nil {
+			return nil, err
+		}
+		for k, v := range raw {
+			values[k] = fmt.Sprint(v)
+		}
+	case ".yaml", ".yml":
+		for i, line := range strings.Split(string(data), "\n") {
+			if hash := strings.Index(line, "#"); hash >= 0 {
+				line = line[:hash]
+			}
+			line = strings.TrimSpace(line)
+			if line == "" || line == "---" {
+				continue
+			}
+			key, value, ok := strings.Cut(line, ":")
+			if !ok {
+				return nil, fmt.Errorf("line %d: expected key: value", i+1)
+			}
+			values[strings.TrimSpace(key)] = strings.Trim(strings.TrimSpace(value), `"'`)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported config format %q, use .json, .yaml or .yml", filepath.Ext(path))
+	}
+	return values, nil
+}
+
+func applyConfig(path string) error {
+	values, err := loadConfig(path)
+	if err != nil {
+		return err
+	}
+	explicit := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) {
+		explicit[f.Name] = true
+	})
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if k == "config" || flag.Lookup(k) == nil {
+			return fmt.Errorf("unknown key %q", k)
+		}
+		if explicit[k] {
+			continue
+		}
+		if err := flag.Set(k, values[k]); err != nil {
+			return fmt.Errorf("%s: %w", k, err)
+		}
+	}
+	return nil
+}
+
+func readInstance(in *bufio.Reader, dims, objectives int) (int, []int, []Item, error) {
+	capacities := make([]int, dims)
+	for d := range capacities {
+		if _, err := fmt.Fscan(in, &capacities[d]); err != nil {
+			return 0, nil, nil, err
+		}
+	}
+	items, err := readItems(in, dims, objectives)
+	if err != nil {
+		return 0, nil, nil, err
+	}
+	return capacities[0], capacities[1:], items, nil
+}
+
+func readItems(in *bufio.Reader, dims, objectives int) ([]Item, error) {
+	var n int
+	if _, err := fmt.Fscan(in, &n); err != nil {
+		return nil, err
+	}
+
+	items := make([]Item, n)
+	for i := 0; i < n; i++ {
+		weights := make([]int, dims)
+		for d := range weights {
+			if _, err := fmt.Fscan(in, &weights[d]); err != nil {
+				return nil, fmt.Errorf("item %d: %w", i+1, err)
+			}
+		}
+		values := make([]int, objectives)
+		for k := range values {
+			if _, err := fmt.Fscan(in, &values[k]); err != nil {
+				return nil, fmt.Errorf("item %d: %w", i+1, err)
+			}
+		}
+		items[i] = Item{Index: i, Weight: weights[0], Value: values[0], Extra: weights[1:]}
+		if objectives > 1 {
+			items[i].Values = values
+		}
+	}
+	return items, nil
+}
+
+type Instance struct {
+	Name          string
+	Capacity      int
+	ExtraCapacity []int
+	Items         []Item
+	Optimum       float64
+	Solution      Genome
+	Conflicts     [][2]int // item pairs that cannot be taken together
+	BinCapacities []int    // several knapsacks instead of one
+}
+
+type instanceSpec struct {
+	Format     string
+	Dims       int
+	Objectives int
+	Variant    string
+}
+
+var instanceFormats = []string{"native", "pisinger", "orlib"}
+
+func readInstances(r io.Reader, name string, spec instanceSpec) ([]Instance, error) {
+	var instances []Instance
+	var err error
+	switch {
+	case spec.Format == "pisinger":
+		instances, err = readPisinger(r)
+	case spec.Format == "orlib":
+		instances, err = readORLibrary(r, name)
+	case spec.Variant == "multiple":
+		instances, err = readMultiple(bufio.NewReader(r), name)
+	default:
+		instances, err = readNative(bufio.NewReader(r), name, spec)
+	}
+	if err != nil {
+		return nil, err
+	}
+	for _, inst := range instances {
+		if err := inst.checkCapacities(); err != nil {
+			return nil, err
+		}
+	}
+	return instances, nil
+}
+
+func readNative(in *bufio.Reader, name string, spec instanceSpec) ([]Instance, error) {
+	capacity, extraCapacity, items, err := readInstance(in, spec.Dims, spec.Objectives)
+	if err != nil {
+		return nil, err
+	}
+	inst := Instance{Name: name, Capacity: capacity, ExtraCapacity: extraCapacity, Items: items}
+	if spec.Variant == "conflict" {
+		if inst.Conflicts, err = readConflicts(in, len(items)); err != nil {
+			return nil, err
+		}
+	}
+	return []Instance{inst}, nil
+}
+
+// checkCapacities rejects zero and negative capacities: the ratio orders divide by them and the
+// NSGA-II repair could never fit a selection below zero.
+func (inst Instance) checkCapacities() error {
+	capacities := append([]int{inst.Capacity}, inst.ExtraCapacity...)
+	capacities = append(capacities, inst.BinCapacities...)
+	for _, c := range capacities {
+		if c <= 0 {
+			return fmt.Errorf("%s: capacity %d is not positive", inst.Name, c)
+		}
+	}
+	return nil
+}
+
+// Conflicts follow the items as a count k and k pairs of 0-based item indices.
+func readConflicts(in *bufio.Reader, n int) ([][2]int, error) {
+	var k int
+	if _, err := fmt.Fscan(in, &k); err != nil {
+		return nil, fmt.Errorf("conflict count: %w", err)
+	}
+	conflicts := make([][2]int, k)
+	for c := range conflicts {
+		a, b := &conflicts[c][0], &conflicts[c][1]
+		if _, err := fmt.Fscan(in, a, b); err != nil {
+			return nil, fmt.Errorf("conflict %d: %w", c+1, err)
+		}
+		if *a < 0 || *b < 0 || *a >= n || *b >= n || *a == *b {
+			return nil, fmt.Errorf("conflict %d: invalid pair %d %d", c+1, *a, *b)
+		}
+	}
+	return conflicts, nil
+}
+
+// Multiple knapsack instances start with the number of bins m and their m capacities instead of
+// the single capacity.
+func readMultiple(in *bufio.Reader, name string) ([]Instance, error) {
+	var m int
+	if _, err := fmt.Fscan(in, &m); err != nil {
+		return nil, err
+	}
+	if m < 1 {
+		return nil, fmt.Errorf("invalid number of bins %d", m)
+	}
+	bins := make([]int, m)
+	total := 0
+	for b := range bins {
+		if _, err := fmt.Fscan(in, &bins[b]); err != nil {
+			return nil, fmt.Errorf("bin %d: %w", b+1, err)
+		}
+		total += bins[b]
+	}
+	items, err := readItems(in, 1, 1)
+	if err != nil {
+		return nil, err
+	}
+	return []Instance{{Name: name, Capacity: total, Items: items, BinCapacities: bins}}, nil
+}
+
+// Pisinger files hold a sequence of instances, each a name line, "n", "c", "z" and "time"
+// lines, n lines "index,profit,weight,x" with the optimal selection x, and a "-----" line.
+func readPisinger(r io.Reader) ([]Instance, error) {
+	var instances []Instance
+	var cur *Instance
+	var taken []int
+	n := -1
+	finish := func() error {
+		if n >= 0 && len(cur.Items) != n {
+			return fmt.Errorf("%s: expected %d items, got %d", cur.Name, n, len(cur.Items))
+		}
+		if len(taken) > 0 {
+			cur.Solution = NewGenome(len(cur.Items))
+			for _, i := range taken {
+				cur.Solution.Set(i)
+			}
+			var c Candidate
+			c.Genes = cur.Solution
+			c.Evaluate(cur.Items, cur.Capacity, nil)
+			if !c.Feasible {
+				return fmt.Errorf("%s: stated solution exceeds the capacity", cur.Name)
+			}
+			if cur.Optimum > 0 && c.Value != cur.Optimum {
+				return fmt.Errorf("%s: stated solution is worth %.0f, stated optimum is %.0f", cur.Name, c.Value, cur.Optimum)
+			}
+			cur.Optimum = c.Value
+		}
+		instances = append(instances, *cur)
+		cur, taken, n = nil, nil, -1
+		return nil
+	}
+
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+	for line := 1; sc.Scan(); line++ {
+		text := strings.TrimSpace(sc.Text())
+		switch {
+		case text == "":
+		case strings.HasPrefix(text, "-----"):
+			if cur != nil {
+				if err := finish(); err != nil {
+					return nil, err
+				}
+			}
+		case cur == nil:
+			cur = &Instance{Name: text}
+		case strings.Contains(text, ","):
+			fields := strings.Split(text, ",")
+			if len(fields) < 3 {
+				return nil, fmt.Errorf("line %d: expected index,profit,weight[,x]", line)
+			}
+			var values [4]int
+			for i := 1; i < len(fields) && i < 4; i++ {
+				v, err := strconv.Atoi(strings.TrimSpace(fields[i]))
+				if err != nil {
+					return nil, fmt.Errorf("line %d: %w", line, err)
+				}
+				values[i] = v
+			}
+			if values[3] == 1 {
+				taken = append(taken, len(cur.Items))
+			}
+			cur.Items = append(cur.Items, Item{Index: len(cur.Items), Weight: values[2], Value: values[1]})
+		default:
+			fields := strings.Fields(text)
+			if len(fields) != 2 {
+				return nil, fmt.Errorf("line %d: unexpected %q", line, text)
+			}
+			v, err := strconv.ParseFloat(fields[1], 64)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", line, err)
+			}
+			switch fields[0] {
+			case "n":
+				n = int(v)
+			case "c":
+				cur.Capacity = int(v)
+			case "z":
+				cur.Optimum = v
+			}
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	if cur != nil {
+		if err := finish(); err != nil {
+			return nil, err
+		}
+	}
+	return instances, nil
+}
+
+// OR-Library knapsack files (mknap1, mknapcb*) start with the number of problems, followed for
+// each by "n m optimum", n profits, m rows of n weights and m capacities. An optimum of 0 means
+// it is unknown.
+func readORLibrary(r io.Reader, name string) ([]Instance, error) {
+	in := bufio.NewReader(r)
+	var count int
+	if _, err := fmt.Fscan(in, &count); err != nil {
+		return nil, err
+	}
+	instances := make([]Instance, count)
+	for p := range instances {
+		var n, m int
+		var optimum float64
+		if _, err := fmt.Fscan(in, &n, &m, &optimum); err != nil {
+			return nil, fmt.Errorf("problem %d: %w", p+1, err)
+		}
+		if n < 0 || m < 1 {
+			return nil, fmt.Errorf("problem %d: invalid size %d x %d", p+1, n, m)
+		}
+		items := make([]Item, n)
+		for i := range items {
+			items[i] = Item{Index: i, Extra: make([]int, m-1)}
+			if _, err := fmt.Fscan(in, &items[i].Value); err != nil {
+				return nil, fmt.Errorf("problem %d: %w", p+1, err)
+			}
+		}
+		for d := 0; d < m; d++ {
+			for i := range items {
+				w := &items[i].Weight
+				if d > 0 {
+					w = &items[i].Extra[d-1]
+				}
+				if _, err := fmt.Fscan(in, w); err != nil {
+					return nil, fmt.Errorf("problem %d: %w", p+1, err)
+				}
+			}
+		}
+		capacities := make([]int, m)
+		for d := range capacities {
+			if _, err := fmt.Fscan(in, &capacities[d]); err != nil {
+				return nil, fmt.Errorf("problem %d: %w", p+1, err)
+			}
+		}
+		instances[p] = Instance{
+			Name:          fmt.Sprintf("%s#%d", name, p+1),
+			Capacity:      capacities[0],
+			ExtraCapacity: capacities[1:],
+			Items:         items,
+			Optimum:       optimum,
+		}
+	}
+	return instances, nil
+}
+
+func (inst Instance) knownOptimum() (float64, string, bool) {
+	if inst.Optimum > 0 {
+		return inst.Optimum, "stated", true
+	}
+	if len(inst.ExtraCapacity) > 0 || inst.variant() != nil {
+		return 0, "", false
+	}
+	return referenceOptimum(inst.Items, inst.Capacity)
+}
+
+func benchmarkFiles(path string) ([]string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return []string{path}, nil
+	}
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for _, e := range entries {
+		if !e.IsDir() && !strings.HasPrefix(e.Name(), ".") {
+			files = append(files, filepath.Join(path, e.Name()))
+		}
+	}
+	return files, nil
+}
+
+func runBatch(path string, spec instanceSpec, params GAParams, repair bool, rng *rand.Rand) error {
+	files, err := benchmarkFiles(path)
+	if err != nil {
+		return err
+	}
+	total, known, hits := 0, 0, 0
+	for _, file := range files {
+		f, err := os.Open(file)
+		if err != nil {
+			return err
+		}
+		instances, err := readInstances(f, filepath.Base(file), spec)
+		f.Close()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Skipping %s: %v\n", file, err)
+			continue
+		}
+		for _, inst := range instances {
+			optimum, _, optimal := inst.knownOptimum()
+			solver := newInstanceSolver(inst, rand.New(rand.NewSource(rng.Int63())))
+			solver.Repair = repair
+			params.apply(solver)
+			if optimal && solver.TargetValue == 0 {
+				solver.TargetValue = optimum
+			}
+			start := time.Now()
+			solver.InitPopulation()
+			value := solver.Run(params.EliteSize, params.TournamentSize).Value
+			elapsed := time.Since(start).Seconds()
+
+			total++
+			fmt.Printf("%-30s n=%-6d best %-10.0f", inst.Name, len(inst.Items), value)
+			if optimal {
+				known++
+				status := "missed"
+				if value >= optimum {
+					hits++
+					status = "solved"
+				}
+				fmt.Printf(" optimum %-10.0f gap %6.2f%% %s", optimum, 100*(optimum-value)/optimum, status)
+			} else {
+				fmt.Printf(" optimum unknown")
+			}
+			fmt.Printf(" %.3f s\n", elapsed)
+		}
+	}
+
+	fmt.Println()
+	if known == 0 {
+		fmt.Printf("%d instances, no known optima\n", total)
+		return nil
+	}
+	fmt.Printf("%d instances, optimum reached on %d/%d (%.1f%%)\n", total, hits, known, 100*float64(hits)/float64(known))
+	return nil
+}
+
+type Solution struct {
+	Items         []int   `json:"items"`
+	Value         int     `json:"value"`
+	Weight        int     `json:"weight"`
+	Capacity      int     `json:"capacity"`
+	Remaining     int     `json:"remaining"`
+	ExtraWeight   []int   `json:"extra_weight,omitempty"`
+	ExtraCapacity []int   `json:"extra_capacity,omitempty"`
+	Bins          [][]int `json:"bins,omitempty"`
+	BinWeights    []int   `json:"bin_weights,omitempty"`
+	BinCapacities []int   `json:"bin_capacities,omitempty"`
+}
+
+func newSolution(inst Instance, genes Genome) Solution {
+	sol := Solution{Items: []int{}, Capacity: inst.Capacity, ExtraCapacity: inst.ExtraCapacity}
+	if len(inst.ExtraCapacity) > 0 {
+		sol.ExtraWeight = make([]int, len(inst.ExtraCapacity))
+	}
+	if inst.BinCapacities != nil {
+		sol.Bins = make([][]int, len(inst.BinCapacities))
+		for b := range sol.Bins {
+			sol.Bins[b] = []int{}
+		}
+		sol.BinWeights = make([]int, len(inst.BinCapacities))
+		sol.BinCapacities = inst.BinCapacities
+	}
+	genes.ForEach(func(i int) {
+		if sol.Bins != nil {
+			b := i / len(inst.Items)
+			i %= len(inst.Items)
+			sol.Bins[b] = append(sol.Bins[b], i)
+			sol.BinWeights[b] += inst.Items[i].Weight
+		}
+		sol.Items = append(sol.Items, i)
+		sol.Value += inst.Items[i].Value
+		sol.Weight += inst.Items[i].Weight
+		addLoad(sol.ExtraWeight, inst.Items[i].Extra, 1)
+	})
+	sort.Ints(sol.Items)
+	sol.Remaining = sol.Capacity - sol.Weight
+	return sol
+}
+
+func joinInts(values []int) string {
+	parts := make([]string, len(values))
+	for i, v := range values {
+		parts[i] = strconv.Itoa(v)
+	}
+	return strings.Join(parts, " ")
+}
+
+func (sol Solution) Print() {
+	fmt.Println("items:", joinInts(sol.Items))
+	for b, bin := range sol.Bins {
+		fmt.Printf("bin %d: %s (weight %d / %d)\n", b+1, joinInts(bin), sol.BinWeights[b], sol.BinCapacities[b])
+	}
+	fmt.Printf("weight: %d / %d (remaining %d)\n", sol.Weight, sol.Capacity, sol.Remaining)
+	for d, w := range sol.ExtraWeight {
+		fmt.Printf("dimension %d: %d / %d (remaining %d)\n", d+2, w, sol.ExtraCapacity[d], sol.ExtraCapacity[d]-w)
+	}
+	fmt.Println("value:", sol.Value)
+}
+
+func writeSolution(path string, sol Solution) error {
+	data, err := json.MarshalIndent(sol, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
+
+// verifySolution recomputes a solution from the instance alone and reports every mismatch
+// with the stored totals.
+func verifySolution(inst Instance, sol Solution) []string {
+	var problems []string
+	n := len(inst.Items)
+	bins := [][]int{sol.Items}
+	genes := NewGenome(n)
+	if inst.BinCapacities != nil {
+		if len(sol.Bins) != len(inst.BinCapacities) {
+			return []string{fmt.Sprintf("solution has %d bins, the instance %d", len(sol.Bins), len(inst.BinCapacities))}
+		}
+		bins = sol.Bins
+		genes = NewGenome(n * len(bins))
+	}
+	seen := make(map[int]bool)
+	for b, bin := range bins {
+		for _, idx := range bin {
+			switch {
+			case idx < 0 || idx >= n:
+				problems = append(problems, fmt.Sprintf("item %d does not exist (instance has %d items)", idx, n))
+			case seen[idx]:
+				problems = append(problems, fmt.Sprintf("item %d is selected twice", idx))
+			default:
+				seen[idx] = true
+				genes.Set(b*n + idx)
+			}
+		}
+	}
+	want := newSolution(inst, genes)
+	if inst.BinCapacities != nil && joinInts(sol.Items) != joinInts(want.Items) {
+		problems = append(problems, "items do not match the contents of the bins")
+	}
+	for b, w := range want.BinWeights {
+		if w > want.BinCapacities[b] {
+			problems = append(problems, fmt.Sprintf("bin %d: weight %d exceeds the capacity %d", b+1, w, want.BinCapacities[b]))
+		}
+	}
+	for _, p := range inst.Conflicts {
+		if seen[p[0]] && seen[p[1]] {
+			problems = append(problems, fmt.Sprintf("items %d and %d conflict", p[0], p[1]))
+		}
+	}
+	if want.Weight > want.Capacity {
+		problems = append(problems, fmt.Sprintf("weight %d exceeds the capacity %d", want.Weight, want.Capacity))
+	}
+	for d, w := range want.ExtraWeight {
+		if w > want.ExtraCapacity[d] {
+			problems = append(problems, fmt.Sprintf("dimension %d: %d exceeds the capacity %d", d+2, w, want.ExtraCapacity[d]))
+		}
+	}
+	check := func(name string, got, expected int) {
+		if got != expected {
+			problems = append(problems, fmt.Sprintf("%s is %d, the instance gives %d", name, got, expected))
+		}
+	}
+	check("capacity", sol.Capacity, want.Capacity)
+	check("value", sol.Value, want.Value)
+	check("weight", sol.Weight, want.Weight)
+	check("remaining", sol.Remaining, want.Remaining)
+	return problems
+}
+
+func runVerify(args []string) int {
+	fs := flag.NewFlagSet("verify", flag.ExitOnError)
+	solutionPath := fs.String("solution", "", "JSON solution file written with -solution")
+	dims := fs.Int("dims", 1, "number of capacity constraints")
+	format := fs.String("format", "native", "instance format: native, pisinger or orlib")
+	variantName := fs.String("variant", "", "instance variant: conflict or multiple")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: main verify -solution file.json [-dims k] [-format f] < instance")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if *solutionPath == "" {
+		fs.Usage()
+		return 2
+	}
+
+	data, err := os.ReadFile(*solutionPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error reading solution:", err)
+		return 2
+	}
+	var sol Solution
+	if err := json.Unmarshal(data, &sol); err != nil {
+		fmt.Fprintln(os.Stderr, "Invalid solution:", err)
+		return 2
+	}
+	instances, err := readInstances(os.Stdin, "stdin", instanceSpec{Format: *format, Dims: *dims, Objectives: 1, Variant: *variantName})
+	if err != nil || len(instances) == 0 {
+		fmt.Fprintln(os.Stderr, "Invalid instance:", err)
+		return 2
+	}
+
+	problems := verifySolution(instances[0], sol)
+	for _, p := range problems {
+		fmt.Println("INVALID:", p)
+	}
+	if len(problems) > 0 {
+		return 1
+	}
+	fmt.Printf("OK: %d items, value %d, weight %d / %d\n", len(sol.Items), sol.Value, sol.Weight, sol.Capacity)
+	return 0
+}
+
+func main() {
+	if len(os.Args) > 1 && os.Args[1] == "verify" {
+		os.Exit(runVerify(os.Args[2:]))
+	}
+
+	measureTime := flag.Bool("time", false, "print elapsed time to stderr")
+	reportGap := flag.Bool("gap", false, "print the gap to the exact optimum (or LP bound) to stderr")
+	exact := flag.String("exact", "", "solve exactly with dp or bb instead of the GA")
+	dims := flag.Int("dims", 1, "number of capacity constraints (weight, volume, cost, ...)")
+	handling := flag.String("handling", "penalty", "overweight children: penalty (fitness 0) or repair")
+	compareRuns := flag.Int("compare", 0, "run penalty and repair this many times each and compare")
+	islands := flag.Int("islands", 1, "number of populations evolving in parallel")
+	topology := flag.String("topology", "ring", "island migration topology: ring or full")
+	migrationInterval := flag.Int("migration-interval", 50, "generations between migrations")
+	migrationSize := flag.Int("migration-size", 2, "best candidates sent by each island per migration")
+	var params GAParams
+	flag.IntVar(&params.PopSize, "pop", 0, "population size (0 picks one from the number of items)")
+	flag.IntVar(&params.Generations, "generations", 0, "number of generations (0 picks one from the number of items)")
+	flag.Float64Var(&params.MutationRate, "mutation", 0.03, "per-gene mutation probability")
+	flag.IntVar(&params.EliteSize, "elite", 5, "best candidates copied unchanged into the next generation")
+	flag.IntVar(&params.TournamentSize, "tournament", 3, "tournament size for tournament selection")
+	flag.StringVar(&params.Selection, "selection", "tournament", "selection: tournament, roulette, rank or sus")
+	flag.StringVar(&params.Crossover, "crossover", "twopoint", "crossover: uniform, onepoint or twopoint")
+	logPath := flag.String("log", "", "write per-generation statistics to a .csv or .jsonl file")
+	chartPath := flag.String("chart", "", "render best/mean/worst fitness per generation to an .svg file")
+	flag.IntVar(&params.StagnationLimit, "stagnation", 0, "generations without improvement that count as stagnation (0 disables)")
+	flag.BoolVar(&params.AdaptiveMutation, "adaptive-mutation", false, "double the mutation rate on stagnation, reset it on improvement")
+	flag.BoolVar(&params.RestartElites, "restart", false, "on stagnation replace everything except the elites with new random candidates")
+	flag.Float64Var(&params.TargetValue, "target", 0, "stop as soon as this value (e.g. a known optimum) is reached")
+	flag.DurationVar(&params.TimeBudget, "time-budget", 0, "stop after this much time, e.g. 2s")
+	format := flag.String("format", "native", "instance format: native, pisinger or orlib")
+	batch := flag.String("batch", "", "run the GA on every instance in this file or directory and report success rates")
+	objectives := flag.Int("objectives", 1, "number of value criteria per item; above 1 runs NSGA-II")
+	minimizeSpec := flag.String("minimize", "", "comma-separated criteria (1-based) to minimise in multi-objective mode, e.g. 2")
+	paretoPath := flag.String("pareto", "", "write the final Pareto front to this CSV file instead of stdout")
+	seed := flag.Int64("seed", 0, "random seed for reproducible runs (0 uses the current time)")
+	runs := flag.Int("runs", 0, "solve this many times and report success rate, best values and time percentiles")
+	showItems := flag.Bool("items", false, "print the selected items, total weight and remaining capacity after the value")
+	solutionPath := flag.String("solution", "", "write the selected items to a JSON file that the verify subcommand can check")
+	checkpointPath := flag.String("checkpoint", "", "periodically save the population to this file (also on Ctrl+C)")
+	checkpointInterval := flag.Int("checkpoint-interval", 100, "generations between checkpoints")
+	resumePath := flag.String("resume", "", "continue the run saved in this checkpoint file")
+	variantName := flag.String("variant", "", "knapsack variant: conflict (item conflict pairs) or multiple (several bins)")
+	configPath := flag.String("config", "", "JSON or YAML file with flag values; command-line flags take precedence")
+	flag.Parse()
+
+	if *configPath != "" {
+		if err := applyConfig(*configPath); err != nil {
+			fmt.Println("Invalid config:", err)
+			return
+		}
+	}
+	if err := params.validate(); err != nil {
+		fmt.Println("Invalid parameters:", err)
+		return
+	}
+	if *seed == 0 {
+		*seed = time.Now().UnixNano()
+	}
+	src := newCountingSource(*seed)
+	rng := rand.New(src)
+
+	if *dims < 1 {
+		fmt.Println("Number of dimensions must be at least 1")
+		return
+	}
+	if *handling != "penalty" && *handling != "repair" {
+		fmt.Println("Unknown constraint handling:", *handling)
+		return
+	}
+	if *topology != "ring" && *topology != "full" {
+		fmt.Println("Unknown topology:", *topology)
+		return
+	}
+	if !contains(instanceFormats, *format) {
+		fmt.Println("Unknown instance format:", *format)
+		return
+	}
+
+	if *objectives < 1 {
+		fmt.Println("Number of objectives must be at least 1")
+		return
+	}
+	minimize, err := parseMinimize(*minimizeSpec, *objectives)
+	if err != nil {
+		fmt.Println("Invalid -minimize:", err)
+		return
+	}
+	if !contains(variantNames, *variantName) {
+		fmt.Println("Unknown variant:", *variantName)
+		return
+	}
+	if *variantName != "" && (*format != "native" || *dims > 1 || *objectives > 1 || *exact != "") {
+		fmt.Println("Variants read native single-capacity instances and are solved by the GA only")
+		return
+	}
+	spec := instanceSpec{Format: *format, Dims: *dims, Objectives: *objectives, Variant: *variantName}
+	if *objectives > 1 && (*format != "native" || *batch != "" || *exact != "" || *compareRuns > 0 || *runs > 0 || *islands > 1) {
+		fmt.Println("Multi-objective mode reads native instances and runs a single population")
+		return
+	}
+
+	if *batch != "" {
+		if err := runBatch(*batch, spec, params, *handling == "repair", rng); err != nil {
+			fmt.Println("Batch failed:", err)
+		}
+		return
+	}
+
+	start := time.Now()
+
+	instances, err := readInstances(os.Stdin, "stdin", spec)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Invalid instance:", err)
+		return
+	}
+	if len(instances) == 0 {
+		return
+	}
+	if len(instances) > 1 {
+		fmt.Fprintf(os.Stderr, "Solving the first of %d instances, use -batch for all\n", len(instances))
+	}
+	inst := instances[0]
+	capacity, extraCapacity, items := inst.Capacity, inst.ExtraCapacity, inst.Items
+	n := len(items)
+	multiDim := len(extraCapacity) > 0
+
+	if *exact != "" && multiDim {
+		fmt.Println("Exact solvers support only a single capacity")
+		return
+	}
+	if *exact != "" {
+		var res ExactResult
+		switch *exact {
+		case "dp":
+			if int64(n)*int64(capacity+1) > dpCellLimit {
+				fmt.Println("Instance too large for dp, use -exact bb")
+				return
+			}
+			res = solveDP(items, capacity)
+		case "bb":
+			res = solveBranchAndBound(items, capacity, bbNodeLimit)
+		default:
+			fmt.Println("Unknown exact solver:", *exact)
+			return
+		}
+		if *measureTime {
+			fmt.Fprintf(os.Stderr, "Elapsed: %.6f seconds\n", time.Since(start).Seconds())
+		}
+		if !res.Optimal {
+			fmt.Fprintln(os.Stderr, "Node limit reached, value is a lower bound")
+		}
+		fmt.Println(res.Value)
+		selected := make([]string, 0, res.Genes.Count())
+		res.Genes.ForEach(func(i int) {
+			selected = append(selected, strconv.Itoa(i))
+		})
+		fmt.Println(strings.Join(selected, " "))
+		if *solutionPath != "" {
+			if err := writeSolution(*solutionPath, newSolution(inst, res.Genes)); err != nil {
+				fmt.Fprintln(os.Stderr, "Error writing solution:", err)
+			}
+		}
+		return
+	}
+
+	if *objectives > 1 {
+		solver := createKnapsackSolver(items, capacity, extraCapacity, rng)
+		params.apply(solver)
+		if params.Generations == 0 {
+			solver.MaxGenerations = nsgaGenerations
+		}
+		moga := &NSGA2{ks: solver, Minimize: minimize}
+		moga.InitPopulation()
+		front := moga.Run()
+		if *measureTime {
+			fmt.Fprintf(os.Stderr, "Elapsed: %.6f seconds\n", time.Since(start).Seconds())
+		}
+		fmt.Fprintf(os.Stderr, "Pareto front: %d solutions\n", len(front))
+		out := io.Writer(os.Stdout)
+		if *paretoPath != "" {
+			f, err := os.Create(*paretoPath)
+			if err != nil {
+				fmt.Println("Error writing Pareto front:", err)
+				return
+			}
+			defer f.Close()
+			out = f
+		}
+		if err := writeParetoFront(out, front, items); err != nil {
+			fmt.Println("Error writing Pareto front:", err)
+		}
+		return
+	}
+
+	if *compareRuns > 0 {
+		compareHandling(inst, params, *compareRuns, rng)
+		return
+	}
+	islandSetup := islandConfig{
+		Count:             *islands,
+		Topology:          *topology,
+		MigrationInterval: *migrationInterval,
+		MigrationSize:     *migrationSize,
+	}
+	if (*checkpointPath != "" || *resumePath != "") && (*islands > 1 || *runs > 0) {
+		fmt.Println("Checkpoints support a single population and a single run")
+		return
+	}
+	if *runs > 0 {
+		benchmarkRuns(inst, params, *handling == "repair", islandSetup, *runs, rng)
+		return
+	}
+
+	recording := *logPath != "" || *chartPath != ""
+	repair := *handling == "repair"
+	var resumed *Checkpoint
+	if *resumePath != "" {
+		cp, err := loadCheckpoint(*resumePath)
+		if err != nil {
+			fmt.Println("Cannot resume:", err)
+			return
+		}
+		if cp.Instance != instanceFingerprint(inst) {
+			fmt.Println("Cannot resume: the checkpoint belongs to a different instance")
+			return
+		}
+		fmt.Fprintf(os.Stderr, "Resuming at generation %d, GA parameters are taken from the checkpoint\n", cp.Generation)
+		resumed, params, repair, *seed = cp, cp.Params, cp.Repair, cp.Seed
+		src = newCountingSource(cp.Seed)
+		src.skip(cp.Draws)
+		rng = rand.New(src)
+	}
+	var interrupt atomic.Bool
+	setup := func(ks *KnapsackSolver) {
+		if resumed != nil {
+			resumed.restore(ks)
+		}
+		if *checkpointPath == "" {
+			return
+		}
+		ks.Interrupt = &interrupt
+		ks.CheckpointInterval = *checkpointInterval
+		ks.OnCheckpoint = func(ks *KnapsackSolver) {
+			if err := writeCheckpoint(*checkpointPath, newCheckpoint(ks, inst, params, src)); err != nil {
+				fmt.Fprintln(os.Stderr, "Error writing checkpoint:", err)
+			}
+		}
+	}
+	if *checkpointPath != "" {
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, os.Interrupt)
+		go func() {
+			<-signals
+			interrupt.Store(true)
+		}()
+	}
+	run := runGA(inst, params, repair, recording, islandSetup, rng, setup)
+	if run.StopReason == "interrupted" {
+		ks := run.Populations[0]
+		ks.OnCheckpoint(ks)
+		fmt.Fprintf(os.Stderr, "Interrupted at generation %d, checkpoint written to %s\n", len(ks.BestValues)-1, *checkpointPath)
+		os.Exit(130)
+	}
+	best, progress, populations, stopReason := run.Best, run.Progress, run.Populations, run.StopReason
+
+	if *measureTime {
+		fmt.Fprintf(os.Stderr, "Elapsed: %.6f seconds (seed %d)\n", time.Since(start).Seconds(), *seed)
+		if stopReason != "" {
+			fmt.Fprintf(os.Stderr, "Stopped after %d generations: %s\n", len(progress)-1, stopReason)
+		}
+	}
+
+	if recording {
+		var stats []GenerationStats
+		for i, ks := range populations {
+			for _, st := range ks.Stats {
+				st.Island = i
+				stats = append(stats, st)
+			}
+		}
+		if *logPath != "" {
+			if err := writeStatsLog(*logPath, stats); err != nil {
+				fmt.Fprintln(os.Stderr, "Error writing log:", err)
+			}
+		}
+		if *chartPath != "" {
+			if err := writeConvergenceChart(*chartPath, combineStats(stats)); err != nil {
+				fmt.Fprintln(os.Stderr, "Error writing chart:", err)
+			}
+		}
+	}
+
+	if len(progress) == 0 {
+		fmt.Println("0")
+		fmt.Println()
+		fmt.Println("0")
+		return
+	}
+
+	lastIndex := len(progress) - 1
+	for i := 0; i < 10; i++ {
+		idx := i * lastIndex / 9
+		fmt.Printf("%.0f\n", progress[idx])
+	}
+
+	fmt.Println()
+	fmt.Printf("%.0f\n", best.Value)
+
+	if *showItems || *solutionPath != "" {
+		genes := best.Genes
+		if !best.Feasible {
+			genes = NewGenome(n)
+		}
+		sol := newSolution(inst, genes)
+		if *showItems {
+			fmt.Println()
+			sol.Print()
+		}
+		if *solutionPath != "" {
+			if err := writeSolution(*solutionPath, sol); err != nil {
+				fmt.Fprintln(os.Stderr, "Error writing solution:", err)
+			}
+		}
+	}
+
+	if *reportGap {
+		ref, method, optimal := inst.knownOptimum()
+		if method == "" {
+			fmt.Fprintln(os.Stderr, "Gap is only available for a single capacity or a stated optimum")
+			return
+		}
+		label := "optimum"
+		if !optimal {
+			label = "upper bound"
+		}
+		gap := 0.0
+		if ref > 0 {
+			gap = 100 * (ref - best.Value) / ref
+		}
+		fmt.Fprintf(os.Stderr, "GA best: %.0f, %s (%s): %.0f, gap: %.2f%%\n", best.Value, label, method, ref, gap)
+	}
 }
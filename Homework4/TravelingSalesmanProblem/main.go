@@ -2,6 +2,7 @@ package main
 
 import (
 	"bufio"
+	"flag"
 	"fmt"
 	"math"
 	"math/rand"
@@ -32,27 +33,86 @@ type TravelingSalesmanProblem struct {
 	Population          []Genome
 	Generations         int
 	MutationProbability float64
+	Closed              bool                   // tours return to the start city
+	Dist                func(i, j int) float64 // distance between cities i and j
+	Mutation            string                 // swap or 2opt
+	matrix              [][]float64
 }
 
-func createRandomGenome(cities []City) Genome {
-	n := len(cities)
+// Up to matrixLimit cities all distances are precomputed (about 32 MB of float64s at the limit);
+// above that the matrix is skipped to keep memory bounded and every distance is computed on demand.
+const matrixLimit = 2000
+
+func (tsp *TravelingSalesmanProblem) precomputeDistances() {
+	n := len(tsp.Cities)
+	if n <= matrixLimit {
+		tsp.matrix = make([][]float64, n)
+		for i := range tsp.matrix {
+			tsp.matrix[i] = make([]float64, n)
+			for j := range tsp.matrix[i] {
+				tsp.matrix[i][j] = tsp.Dist(i, j)
+			}
+		}
+	}
+}
+
+func (tsp *TravelingSalesmanProblem) distance(i, j int) float64 {
+	if tsp.matrix != nil {
+		return tsp.matrix[i][j]
+	}
+	return tsp.Dist(i, j)
+}
+
+// edgeSum adds up the distinct edges leaving the given route positions; edge k joins position k
+// and the next one. Comparing it before and after a move gives the change in length in O(1).
+func (tsp *TravelingSalesmanProblem) edgeSum(route []int, edges ...int) float64 {
+	n := len(route)
+	sum := 0.0
+	for idx, k := range edges {
+		if k < 0 {
+			k += n
+		}
+		if k < 0 || (!tsp.Closed && k >= n-1) {
+			continue
+		}
+		duplicate := false
+		for _, other := range edges[:idx] {
+			duplicate = duplicate || (other+n)%n == k
+		}
+		if !duplicate {
+			sum += tsp.distance(route[k], route[(k+1)%n])
+		}
+	}
+	return sum
+}
+
+func createRandomGenome(n int, dist func(i, j int) float64, closed bool) Genome {
 	route := rand.Perm(n)
 	g := Genome{Route: route}
-	g.Distance = calculateDistance(g.Route, cities)
+	g.Distance = calculateDistance(g.Route, dist, closed)
 	g.Fitness = 1.0 / (g.Distance + 1.0)
 	return g
 }
 
-func createTravelingSalesmanProblem(cities []City, populationSize, generations int, mutationProbability float64) *TravelingSalesmanProblem {
+// createTravelingSalesmanProblem uses the Euclidean distance between the cities when dist is nil.
+func createTravelingSalesmanProblem(cities []City, dist func(i, j int) float64, populationSize, generations int, mutationProbability float64, closed bool) *TravelingSalesmanProblem {
+	if dist == nil {
+		dist = func(i, j int) float64 {
+			return cityDistance(cities[i], cities[j])
+		}
+	}
 	tsp := &TravelingSalesmanProblem{
 		Cities:              cities,
 		PopulationSize:      populationSize,
 		Generations:         generations,
 		MutationProbability: mutationProbability,
+		Closed:              closed,
+		Dist:                dist,
 		Population:          make([]Genome, populationSize),
 	}
+	tsp.precomputeDistances()
 	for i := 0; i < populationSize; i++ {
-		tsp.Population[i] = createRandomGenome(tsp.Cities)
+		tsp.Population[i] = createRandomGenome(len(cities), tsp.distance, closed)
 	}
 
 	sort.Slice(tsp.Population, func(i, j int) bool {
@@ -61,22 +121,37 @@ func createTravelingSalesmanProblem(cities []City, populationSize, generations i
 	return tsp
 }
 
-func calculateDistance(route []int, cities []City) float64 {
+func cityDistance(from, to City) float64 {
+	dx := to.X - from.X
+	dy := to.Y - from.Y
+	return math.Sqrt(math.Pow(dx, 2) + math.Pow(dy, 2))
+}
+
+func calculateDistance(route []int, dist func(i, j int) float64, closed bool) float64 {
 	totalDistance := 0.0
 	if len(route) == 0 {
 		return totalDistance
 	}
 	for i := 0; i < len(route)-1; i++ {
-		fromCity := cities[route[i]]
-		toCity := cities[route[i+1]]
-		dx := toCity.X - fromCity.X
-		dy := toCity.Y - fromCity.Y
-		totalDistance += math.Sqrt(math.Pow(dx, 2) + math.Pow(dy, 2))
+		totalDistance += dist(route[i], route[i+1])
+	}
+	if closed && len(route) > 1 {
+		totalDistance += dist(route[len(route)-1], route[0])
 	}
 
 	return totalDistance
 }
 
+// rotateToStart returns a closed tour rotated so that it starts at city 0; the length is unchanged.
+func rotateToStart(route []int) []int {
+	for i, city := range route {
+		if city == 0 {
+			return append(append([]int{}, route[i:]...), route[:i]...)
+		}
+	}
+	return route
+}
+
 func (tsp *TravelingSalesmanProblem) tournamentSelection(k int) Genome {
 	if len(tsp.Population) == 0 {
 		return Genome{}
@@ -114,16 +189,13 @@ func (tsp *TravelingSalesmanProblem) crossover(parent1, parent2 Genome) []Genome
 		child2Route[idx] = parent2.Route[idx]
 	}
 	fillFrom := func(child []int, donor []int) {
+		taken := make([]bool, n)
+		for idx := i; idx <= j; idx++ {
+			taken[child[idx]] = true
+		}
 		pos := (j + 1) % n
 		for _, gene := range donor {
-			already := false
-			for _, v := range child {
-				if v == gene {
-					already = true
-					break
-				}
-			}
-			if already {
+			if taken[gene] {
 				continue
 			}
 			for child[pos] != -1 {
@@ -137,11 +209,11 @@ func (tsp *TravelingSalesmanProblem) crossover(parent1, parent2 Genome) []Genome
 	fillFrom(child2Route, parent1.Route)
 
 	child1 := Genome{Route: child1Route}
-	child1.Distance = calculateDistance(child1.Route, tsp.Cities)
+	child1.Distance = calculateDistance(child1.Route, tsp.distance, tsp.Closed)
 	child1.Fitness = 1.0 / (child1.Distance + 1.0)
 
 	child2 := Genome{Route: child2Route}
-	child2.Distance = calculateDistance(child2.Route, tsp.Cities)
+	child2.Distance = calculateDistance(child2.Route, tsp.distance, tsp.Closed)
 	child2.Fitness = 1.0 / (child2.Distance + 1.0)
 
 	return []Genome{child1, child2}
@@ -154,8 +226,20 @@ func (tsp *TravelingSalesmanProblem) mutate(g Genome) Genome {
 	if rand.Float64() < tsp.MutationProbability {
 		i := rand.Intn(len(g.Route))
 		j := rand.Intn(len(g.Route))
-		g.Route[i], g.Route[j] = g.Route[j], g.Route[i]
-		g.Distance = calculateDistance(g.Route, tsp.Cities)
+		if i > j {
+			i, j = j, i
+		}
+		if tsp.Mutation == "2opt" {
+			before := tsp.edgeSum(g.Route, i-1, j)
+			for a, b := i, j; a < b; a, b = a+1, b-1 {
+				g.Route[a], g.Route[b] = g.Route[b], g.Route[a]
+			}
+			g.Distance += tsp.edgeSum(g.Route, i-1, j) - before
+		} else {
+			before := tsp.edgeSum(g.Route, i-1, i, j-1, j)
+			g.Route[i], g.Route[j] = g.Route[j], g.Route[i]
+			g.Distance += tsp.edgeSum(g.Route, i-1, i, j-1, j) - before
+		}
 		g.Fitness = 1.0 / (g.Distance + 1.0)
 	}
 	return g
@@ -195,6 +279,12 @@ func (tsp *TravelingSalesmanProblem) evolvePopulationWithElitism(eliteCount int)
 	sort.Slice(tsp.Population, func(i, j int) bool {
 		return tsp.Population[i].Fitness > tsp.Population[j].Fitness
 	})
+
+	// Mutations update lengths by deltas; the best route is measured exactly so that printed
+	// lengths match the route without rounding drift.
+	best := &tsp.Population[0]
+	best.Distance = calculateDistance(best.Route, tsp.distance, tsp.Closed)
+	best.Fitness = 1.0 / (best.Distance + 1.0)
 }
 
 func (tsp *TravelingSalesmanProblem) runEvolution() (Genome, []float64) {
@@ -267,6 +357,236 @@ func loadFiles(cities *[]City, fileNamesPath, fileCoordinatesPath string) error
 	return nil
 }
 
+type tsplibInstance struct {
+	Name   string
+	Cities []City
+	Dist   func(i, j int) float64
+}
+
+func nint(x float64) float64 {
+	return math.Floor(x + 0.5)
+}
+
+// geoRadians converts a TSPLIB GEO coordinate (degrees.minutes) to radians.
+func geoRadians(x float64) float64 {
+	deg := math.Trunc(x)
+	return 3.141592 * (deg + 5.0*(x-deg)/3.0) / 180.0
+}
+
+func tsplibDistance(weightType string, cities []City) (func(i, j int) float64, error) {
+	switch weightType {
+	case "EUC_2D":
+		return func(i, j int) float64 {
+			return nint(math.Hypot(cities[i].X-cities[j].X, cities[i].Y-cities[j].Y))
+		}, nil
+	case "CEIL_2D":
+		return func(i, j int) float64 {
+			return math.Ceil(math.Hypot(cities[i].X-cities[j].X, cities[i].Y-cities[j].Y))
+		}, nil
+	case "ATT":
+		return func(i, j int) float64 {
+			dx, dy := cities[i].X-cities[j].X, cities[i].Y-cities[j].Y
+			r := math.Sqrt((dx*dx + dy*dy) / 10.0)
+			if t := nint(r); t < r {
+				return t + 1
+			} else {
+				return t
+			}
+		}, nil
+	case "GEO":
+		return func(i, j int) float64 {
+			if i == j {
+				return 0
+			}
+			const rrr = 6378.388
+			lat1, lon1 := geoRadians(cities[i].X), geoRadians(cities[i].Y)
+			lat2, lon2 := geoRadians(cities[j].X), geoRadians(cities[j].Y)
+			q1 := math.Cos(lon1 - lon2)
+			q2 := math.Cos(lat1 - lat2)
+			q3 := math.Cos(lat1 + lat2)
+			return math.Trunc(rrr*math.Acos(0.5*((1.0+q1)*q2-(1.0-q1)*q3)) + 1.0)
+		}, nil
+	}
+	return nil, fmt.Errorf("unsupported EDGE_WEIGHT_TYPE %s", weightType)
+}
+
+// explicitMatrix fills a symmetric matrix from the EDGE_WEIGHT_SECTION numbers in the given
+// EDGE_WEIGHT_FORMAT.
+func explicitMatrix(format string, n int, weights []float64) ([][]float64, error) {
+	m := make([][]float64, n)
+	for i := range m {
+		m[i] = make([]float64, n)
+	}
+	var cells [][2]int
+	for i := 0; i < n; i++ {
+		for j := 0; j < n; j++ {
+			switch format {
+			case "FULL_MATRIX":
+				cells = append(cells, [2]int{i, j})
+			case "UPPER_ROW":
+				if j > i {
+					cells = append(cells, [2]int{i, j})
+				}
+			case "LOWER_ROW":
+				if j < i {
+					cells = append(cells, [2]int{i, j})
+				}
+			case "UPPER_DIAG_ROW":
+				if j >= i {
+					cells = append(cells, [2]int{i, j})
+				}
+			case "LOWER_DIAG_ROW":
+				if j <= i {
+					cells = append(cells, [2]int{i, j})
+				}
+			default:
+				return nil, fmt.Errorf("unsupported EDGE_WEIGHT_FORMAT %s", format)
+			}
+		}
+	}
+	if len(weights) != len(cells) {
+		return nil, fmt.Errorf("%s with dimension %d needs %d weights, got %d", format, n, len(cells), len(weights))
+	}
+	for k, c := range cells {
+		m[c[0]][c[1]] = weights[k]
+		if format != "FULL_MATRIX" {
+			m[c[1]][c[0]] = weights[k]
+		}
+	}
+	return m, nil
+}
+
+func loadTSPLIB(path string) (*tsplibInstance, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	header := make(map[string]string)
+	var coords, display, weights []float64
+	var section *[]float64
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || line == "EOF" {
+			continue
+		}
+		if key, value, ok := strings.Cut(line, ":"); ok && section == nil {
+			header[strings.TrimSpace(key)] = strings.TrimSpace(value)
+			continue
+		}
+		switch line {
+		case "NODE_COORD_SECTION":
+			section = &coords
+			continue
+		case "DISPLAY_DATA_SECTION":
+			section = &display
+			continue
+		case "EDGE_WEIGHT_SECTION":
+			section = &weights
+			continue
+		}
+		if section == nil {
+			return nil, fmt.Errorf("unexpected line %q", line)
+		}
+		for _, field := range strings.Fields(line) {
+			v, err := strconv.ParseFloat(field, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid number %q", field)
+			}
+			*section = append(*section, v)
+		}
+	}
+
+	if t := header["TYPE"]; t != "" && t != "TSP" {
+		return nil, fmt.Errorf("unsupported TYPE %s, only symmetric TSP", t)
+	}
+	n, err := strconv.Atoi(header["DIMENSION"])
+	if err != nil || n < 1 {
+		return nil, fmt.Errorf("invalid DIMENSION %q", header["DIMENSION"])
+	}
+	inst := &tsplibInstance{Name: header["NAME"], Cities: make([]City, n)}
+	if inst.Name == "" {
+		inst.Name = strings.TrimSuffix(filepath.Base(path), ".tsp")
+	}
+	for i := range inst.Cities {
+		inst.Cities[i] = City{Index: i, Name: strconv.Itoa(i + 1)}
+	}
+	for _, xy := range [][]float64{coords, display} {
+		if len(xy) == 0 {
+			continue
+		}
+		if len(xy) != 3*n {
+			return nil, fmt.Errorf("expected %d lines of \"id x y\" coordinates", n)
+		}
+		for k := 0; k < n; k++ {
+			id := int(xy[3*k])
+			if id < 1 || id > n {
+				return nil, fmt.Errorf("invalid node id %d", id)
+			}
+			inst.Cities[id-1].X, inst.Cities[id-1].Y = xy[3*k+1], xy[3*k+2]
+		}
+	}
+
+	weightType := header["EDGE_WEIGHT_TYPE"]
+	if weightType == "EXPLICIT" {
+		matrix, err := explicitMatrix(header["EDGE_WEIGHT_FORMAT"], n, weights)
+		if err != nil {
+			return nil, err
+		}
+		inst.Dist = func(i, j int) float64 {
+			return matrix[i][j]
+		}
+		return inst, nil
+	}
+	if len(coords) == 0 {
+		return nil, fmt.Errorf("missing NODE_COORD_SECTION")
+	}
+	if inst.Dist, err = tsplibDistance(weightType, inst.Cities); err != nil {
+		return nil, err
+	}
+	return inst, nil
+}
+
+// loadOptTour reads the TOUR_SECTION of a TSPLIB .opt.tour file as 0-based city indices.
+func loadOptTour(path string, n int) ([]int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var tour []int
+	inSection := false
+	seen := make([]bool, n)
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "TOUR_SECTION" {
+			inSection = true
+			continue
+		}
+		if !inSection || line == "" {
+			continue
+		}
+		for _, field := range strings.Fields(line) {
+			id, err := strconv.Atoi(field)
+			if err != nil {
+				return nil, fmt.Errorf("invalid node %q", field)
+			}
+			if id == -1 {
+				inSection = false
+				break
+			}
+			if id < 1 || id > n || seen[id-1] {
+				return nil, fmt.Errorf("invalid or repeated node %d", id)
+			}
+			seen[id-1] = true
+			tour = append(tour, id-1)
+		}
+	}
+	if len(tour) != n {
+		return nil, fmt.Errorf("tour visits %d of %d cities", len(tour), n)
+	}
+	return tour, nil
+}
+
 func generateRandomCities(cities *[]City, count int) {
 	for i := 0; i < count; i++ {
 		*cities = append(*cities, City{
@@ -279,19 +599,61 @@ func generateRandomCities(cities *[]City, count int) {
 }
 
 func main() {
+	closed := flag.Bool("closed", false, "optimise closed tours that return to the start city (default for -tsplib)")
+	mutation := flag.String("mutation", "swap", "mutation: swap two cities or 2opt (reverse a segment)")
+	tsplibPath := flag.String("tsplib", "", "read cities from a TSPLIB .tsp file instead of stdin")
+	optTourPath := flag.String("opt-tour", "", "TSPLIB .opt.tour file with the optimal tour (default: next to the .tsp file)")
+	flag.Parse()
+	if *mutation != "swap" && *mutation != "2opt" {
+		fmt.Println("Unknown mutation:", *mutation)
+		return
+	}
+	closedSet := false
+	flag.Visit(func(f *flag.Flag) {
+		closedSet = closedSet || f.Name == "closed"
+	})
+
 	rand.Seed(time.Now().UnixNano())
 
 	timeOnly := os.Getenv("FMI_TIME_ONLY") == "1"
 
-	reader := bufio.NewReader(os.Stdin)
-	inputLine, _ := reader.ReadString('\n')
-	input := strings.TrimSpace(inputLine)
+	var input string
+	if *tsplibPath == "" {
+		reader := bufio.NewReader(os.Stdin)
+		inputLine, _ := reader.ReadString('\n')
+		input = strings.TrimSpace(inputLine)
+	}
 
 	startTime := time.Now()
 
 	var cities []City
+	var dist func(i, j int) float64
+	var optTour []int
 
-	if input == "UK12" {
+	if *tsplibPath != "" {
+		inst, err := loadTSPLIB(*tsplibPath)
+		if err != nil {
+			fmt.Println("Error loading TSPLIB instance:", err)
+			return
+		}
+		cities, dist = inst.Cities, inst.Dist
+		if !closedSet {
+			*closed = true
+		}
+		path := *optTourPath
+		if path == "" {
+			path = strings.TrimSuffix(*tsplibPath, ".tsp") + ".opt.tour"
+			if _, err := os.Stat(path); err != nil {
+				path = ""
+			}
+		}
+		if path != "" {
+			if optTour, err = loadOptTour(path, len(cities)); err != nil {
+				fmt.Println("Error loading optimal tour:", err)
+				return
+			}
+		}
+	} else if input == "UK12" {
 		namesPath := filepath.Join("resource", "uk12_name.csv")
 		coordsPath := filepath.Join("resource", "uk12_xy.csv")
 		if err := loadFiles(&cities, namesPath, coordsPath); err != nil {
@@ -311,7 +673,8 @@ func main() {
 		generateRandomCities(&cities, countOfCities)
 	}
 
-	tsp := createTravelingSalesmanProblem(cities, 350, 2500, 0.5)
+	tsp := createTravelingSalesmanProblem(cities, dist, 350, 2500, 0.5, *closed)
+	tsp.Mutation = *mutation
 
 	bestGenome, bestDistances := tsp.runEvolution()
 
@@ -322,6 +685,7 @@ func main() {
 		return
 	}
 
+	named := input == "UK12" || *tsplibPath != ""
 	distFormat := "%.4f"
 	if input == "UK12" {
 		distFormat = "%.12f"
@@ -337,15 +701,30 @@ func main() {
 	}
 	fmt.Println()
 
-	if input == "UK12" {
-		path := make([]string, 0, len(cities))
-		for i := 0; i < len(cities); i++ {
-			currentCity := cities[bestGenome.Route[i]]
-			path = append(path, currentCity.Name)
+	if named {
+		route := bestGenome.Route
+		if *closed {
+			route = rotateToStart(route)
+			route = append(route, route[0])
+		}
+		path := make([]string, 0, len(route))
+		for _, city := range route {
+			path = append(path, cities[city].Name)
 		}
 		fmt.Println(strings.Join(path, " -> "))
 		fmt.Printf(distFormat+"\n", bestGenome.Distance)
 	} else {
 		fmt.Printf("%.4f\n", bestGenome.Distance)
 	}
+
+	if optTour != nil && !tsp.Closed {
+		fmt.Fprintln(os.Stderr, "The optimal tour is closed, no gap for open paths")
+	} else if optTour != nil {
+		optimum := calculateDistance(optTour, tsp.Dist, true)
+		gap := 0.0
+		if optimum > 0 {
+			gap = 100 * (bestGenome.Distance - optimum) / optimum
+		}
+		fmt.Fprintf(os.Stderr, "Optimal tour: %.4f, gap: %.2f%%\n", optimum, gap)
+	}
 }